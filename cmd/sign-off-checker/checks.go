@@ -0,0 +1,362 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// The vendored go-github predates the Checks API, so check runs are created
+// with raw requests using the types below.
+
+const mediaTypeChecksPreview = "application/vnd.github.antiope-preview+json"
+
+// rerunIdentifier identifies the "Re-run" action on our check runs.
+const rerunIdentifier = "rerun"
+
+type checkRun struct {
+	Name        string           `json:"name"`
+	HeadSHA     string           `json:"head_sha"`
+	DetailsURL  string           `json:"details_url,omitempty"`
+	Status      string           `json:"status"`
+	Conclusion  string           `json:"conclusion,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Output      *checkRunOutput  `json:"output,omitempty"`
+	Actions     []checkRunAction `json:"actions,omitempty"`
+}
+
+type checkRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+type checkRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+// checksReporter creates a completed check run on the head commit of the PR
+// with a table describing every commit. Check runs can only be created when
+// authenticated as a GitHub App.
+type checksReporter struct{}
+
+func (checksReporter) Report(client *github.Client, owner, repo, headSHA string, results []commitResult, cfg *repoConfig) error {
+	unsigned := unsignedSHAs(results)
+	now := time.Now()
+	run := &checkRun{
+		Name:        cfg.Context,
+		HeadSHA:     headSHA,
+		DetailsURL:  cfg.HelpURL,
+		Status:      "completed",
+		CompletedAt: &now,
+		Output: &checkRunOutput{
+			Text: checkRunText(results, cfg),
+		},
+		Actions: []checkRunAction{{
+			Label:       "Re-run",
+			Description: "Check the sign-offs again",
+			Identifier:  rerunIdentifier,
+		}},
+	}
+	if len(unsigned) > 0 {
+		run.Conclusion = "failure"
+		run.Output.Title = fmt.Sprintf("%d of %d commits failed", len(unsigned), len(results))
+		run.Output.Summary = fmt.Sprintf("%s %s.\n\nSee %s for how to fix this.",
+			cfg.Messages.Failure, strings.Join(cfg.failures(results), ", "), cfg.HelpURL)
+	} else {
+		run.Conclusion = "success"
+		run.Output.Title = cfg.Messages.Success
+		run.Output.Summary = fmt.Sprintf("Every one of the %d commits %s.", len(results), cfg.rule.Description())
+	}
+	if suffix := exemptSuffix(results); suffix != "" {
+		run.Output.Title += suffix
+	}
+
+	current, err := currentCheckRun(client, owner, repo, headSHA, cfg.Context)
+	if err != nil {
+		log.Printf("Error getting check runs for %s: %v", shortSHA(headSHA), err)
+	} else if current != nil && sameCheckRun(current, run) {
+		return nil
+	}
+
+	return createCheckRun(client, owner, repo, run)
+}
+
+func (checksReporter) Override(client *github.Client, owner, repo, headSHA, description string, cfg *repoConfig) error {
+	now := time.Now()
+	run := &checkRun{
+		Name:        cfg.Context,
+		HeadSHA:     headSHA,
+		DetailsURL:  cfg.HelpURL,
+		Status:      "completed",
+		Conclusion:  "success",
+		CompletedAt: &now,
+		Output: &checkRunOutput{
+			Title:   truncate(description, maxDescriptionLen),
+			Summary: description + "\n\nThe commits will be checked again when the PR is next pushed to.",
+		},
+	}
+	return createCheckRun(client, owner, repo, run)
+}
+
+// createCheckRun creates a check run on run.HeadSHA.
+func createCheckRun(client *github.Client, owner, repo string, run *checkRun) error {
+	created := func() (bool, error) {
+		current, err := currentCheckRun(client, owner, repo, run.HeadSHA, run.Name)
+		return current != nil && sameCheckRun(current, run), err
+	}
+	return createOnGitHub("Checks.CreateCheckRun", fmt.Sprintf("Creating check run on %s/%s@%s", owner, repo, shortSHA(run.HeadSHA)), func() (*github.Response, error) {
+		// The request body can only be read once, so build a new
+		// request for every attempt.
+		req, err := client.NewRequest("POST", fmt.Sprintf("repos/%s/%s/check-runs", owner, repo), run)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", mediaTypeChecksPreview)
+		return client.Do(context.TODO(), req, nil)
+	}, created)
+}
+
+// sameCheckRun reports whether current, as listed by GitHub, reports the
+// same result as run.
+func sameCheckRun(current, run *checkRun) bool {
+	return current.Status == run.Status && current.Conclusion == run.Conclusion &&
+		current.Output != nil && run.Output != nil && *current.Output == *run.Output
+}
+
+func (checksReporter) Overridden(client *github.Client, owner, repo, headSHA string, cfg *repoConfig) (bool, error) {
+	current, err := currentCheckRun(client, owner, repo, headSHA, cfg.Context)
+	if err != nil || current == nil {
+		return false, err
+	}
+	return current.Conclusion == "success" && current.Output != nil &&
+		strings.HasPrefix(current.Output.Title, overridePrefix), nil
+}
+
+// currentCheckRun returns the latest check run with the given name on a
+// commit, or nil if there isn't one.
+func currentCheckRun(client *github.Client, owner, repo, sha, name string) (*checkRun, error) {
+	u := fmt.Sprintf("repos/%s/%s/commits/%s/check-runs?check_name=%s", owner, repo, sha, url.QueryEscape(name))
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaTypeChecksPreview)
+	list := struct {
+		CheckRuns []*checkRun `json:"check_runs"`
+	}{}
+	err = callGitHub("Checks.ListCheckRunsForRef", fmt.Sprintf("Listing check runs on %s/%s@%s", owner, repo, shortSHA(sha)), func() (*github.Response, error) {
+		return client.Do(context.TODO(), req, &list)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.CheckRuns) == 0 {
+		return nil, nil
+	}
+	return list.CheckRuns[0], nil
+}
+
+func checkRunText(results []commitResult, cfg *repoConfig) string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "| Commit | Author | Result |")
+	fmt.Fprintln(&b, "| --- | --- | --- |")
+	for _, result := range results {
+		outcome := ":white_check_mark: Passes"
+		if result.Exempt != "" {
+			outcome = ":heavy_minus_sign: Exempt: " + result.Exempt
+		} else if result.Err != nil {
+			outcome = ":x: " + result.Err.Error()
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n",
+			shortSHA(result.Commit.GetSHA()),
+			escapeCell(commitAuthor(result.Commit)),
+			escapeCell(outcome))
+	}
+	return b.String()
+}
+
+// checkRunPR is a PR as listed in check run and check suite events.
+type checkRunPR struct {
+	Number int `json:"number"`
+	Base   struct {
+		SHA string `json:"sha"`
+	} `json:"base"`
+}
+
+// checkApp is the app that created a check run or suite.
+type checkApp struct {
+	ID int `json:"id"`
+}
+
+// ours reports whether we created the check run or suite. Only a GitHub
+// App can create them, so there are none of ours when using a token.
+func (a checkApp) ours() bool {
+	return app != nil && a.ID == app.ID
+}
+
+// checkRunEvent is the payload of a check_run webhook.
+type checkRunEvent struct {
+	Action   string `json:"action"`
+	CheckRun struct {
+		Name         string       `json:"name"`
+		App          checkApp     `json:"app"`
+		HeadSHA      string       `json:"head_sha"`
+		PullRequests []checkRunPR `json:"pull_requests"`
+	} `json:"check_run"`
+	RequestedAction *struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+	Repo         *github.Repository   `json:"repository"`
+	Installation *github.Installation `json:"installation"`
+}
+
+// checkSuiteEvent is the payload of a check_suite webhook.
+type checkSuiteEvent struct {
+	Action     string `json:"action"`
+	CheckSuite struct {
+		HeadSHA      string       `json:"head_sha"`
+		App          checkApp     `json:"app"`
+		PullRequests []checkRunPR `json:"pull_requests"`
+	} `json:"check_suite"`
+	Repo         *github.Repository   `json:"repository"`
+	Installation *github.Installation `json:"installation"`
+}
+
+// HandleCheckRun queues a check of the PRs of one of our check runs when
+// someone clicks its "Re-run" button, or asks GitHub to re-run it.
+func HandleCheckRun(payload []byte) error {
+	event := checkRunEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	rerun := event.Action == "rerequested" ||
+		(event.Action == "requested_action" && event.RequestedAction != nil &&
+			event.RequestedAction.Identifier == rerunIdentifier)
+	if !rerun {
+		return nil
+	}
+	if !event.CheckRun.App.ours() {
+		log.Printf("Ignoring %s of check run %q, which isn't ours", event.Action, event.CheckRun.Name)
+		return nil
+	}
+	return recheckPullRequests("check_run", event.Installation, event.Repo, event.CheckRun.PullRequests, event.CheckRun.HeadSHA)
+}
+
+// HandleCheckSuite queues a check of the PRs of a check suite when someone
+// asks GitHub to re-run all of its checks.
+func HandleCheckSuite(payload []byte) error {
+	event := checkSuiteEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	if event.Action != "rerequested" {
+		return nil
+	}
+	if !event.CheckSuite.App.ours() {
+		log.Printf("Ignoring %s of a check suite that isn't ours", event.Action)
+		return nil
+	}
+	return recheckPullRequests("check_suite", event.Installation, event.Repo, event.CheckSuite.PullRequests, event.CheckSuite.HeadSHA)
+}
+
+// recheckPullRequests queues a check of each PR of a check run or suite.
+// GitHub doesn't list PRs from forks in check events, so if there are none
+// the open PRs with the same head commit are looked up instead.
+func recheckPullRequests(hooktype string, installation *github.Installation, repo *github.Repository, prs []checkRunPR, headSHA string) error {
+	if repo == nil || repo.Owner == nil {
+		return fmt.Errorf("%s event has no repository", hooktype)
+	}
+	owner, name := repo.Owner.GetLogin(), repo.GetName()
+
+	if len(prs) == 0 {
+		if headSHA == "" {
+			log.Printf("Ignoring %s event for %s, which has no PRs or head commit", hooktype, repo.GetFullName())
+			return nil
+		}
+		key := fmt.Sprintf("%s/%s@%s rerun", owner, name, headSHA)
+		return enqueue(installation, key, true, func(client *github.Client) {
+			recheckPullRequestsByHead(client, installation, owner, name, headSHA)
+		})
+	}
+
+	var err error
+	for _, pr := range prs {
+		log.Printf("Re-running check for %s#%d", repo.GetFullName(), pr.Number)
+		err = enqueueCheck(installation, owner, name, pr.Number, pr.Base.SHA, headSHA)
+		if err != errQueued {
+			return err
+		}
+	}
+	return err
+}
+
+// recheckPullRequestsByHead queues a check of each open PR whose head is
+// headSHA.
+func recheckPullRequestsByHead(client *github.Client, installation *github.Installation, owner, repo, headSHA string) {
+	prs, err := openPullRequestsWithHead(client, owner, repo, headSHA)
+	if err != nil {
+		log.Printf("Error listing PRs of %s/%s: %v", owner, repo, err)
+		return
+	}
+	if len(prs) == 0 {
+		log.Printf("Not re-running check of %s/%s@%s: no open PR has it as its head", owner, repo, shortSHA(headSHA))
+		return
+	}
+	for _, pr := range prs {
+		log.Printf("Re-running check for %s/%s#%d", owner, repo, pr.GetNumber())
+		enqueueCheck(installation, owner, repo, pr.GetNumber(), pr.Base.GetSHA(), headSHA)
+	}
+}
+
+// openPullRequestsWithHead returns the open PRs whose head is sha.
+func openPullRequestsWithHead(client *github.Client, owner, repo, sha string) ([]*github.PullRequest, error) {
+	opt := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	found := []*github.PullRequest{}
+	for {
+		var prs []*github.PullRequest
+		var resp *github.Response
+		err := callGitHub("PullRequests.List", fmt.Sprintf("Listing PRs of %s/%s", owner, repo), func() (*github.Response, error) {
+			var err error
+			prs, resp, err = client.PullRequests.List(context.TODO(), owner, repo, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			if pr.Head != nil && pr.Head.GetSHA() == sha && pr.Base != nil {
+				found = append(found, pr)
+			}
+		}
+		if resp.NextPage == 0 {
+			return found, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
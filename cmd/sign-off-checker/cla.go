@@ -0,0 +1,139 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/heptio/sign-off-checker/pkg/cla"
+	"github.com/heptio/sign-off-checker/pkg/policy"
+)
+
+// claRegistry records who has signed a CLA. It is nil if none is
+// configured.
+var claRegistry cla.Registry
+
+// claUnlinkedEmails looks up the emails of commits that aren't linked to a
+// GitHub account. It is only set when checking local commits, since anyone
+// can put any email in a commit pushed to GitHub.
+var claUnlinkedEmails bool
+
+var claSettings = []*setting{
+	{Name: "cla-registry", Env: "CLA_REGISTRY",
+		Usage: "JSON file of signed CLAs, for repos that require one."},
+}
+
+// newCLARegistry returns the configured CLA registry, or nil if there is
+// none.
+func newCLARegistry(set *settings) (cla.Registry, error) {
+	file := set.String("cla-registry")
+	if file == "" {
+		return nil, nil
+	}
+	registry, err := cla.NewFileRegistry(file)
+	if err != nil {
+		return nil, fmt.Errorf("cla-registry: %v", err)
+	}
+	return registry, nil
+}
+
+// claConfig turns on checking that the author of every commit is covered by
+// a signed CLA.
+type claConfig struct {
+	// URL is the page where the CLA is signed. The CLA is checked if it is
+	// set.
+	URL string `json:"url"`
+
+	// Context is the name of the status or check run. It defaults to "cla".
+	Context string `json:"context"`
+
+	// Only turns off the rules about commit messages, so that only the CLA
+	// is checked.
+	Only bool `json:"only"`
+}
+
+// claRule checks that commit authors are covered by a CLA in claRegistry.
+type claRule struct {
+	name string
+	url  string
+}
+
+func (r *claRule) Name() string { return r.name }
+
+func (r *claRule) Description() string {
+	return "is by an author covered by a CLA"
+}
+
+func (r *claRule) Check(commit *policy.Commit) error {
+	if claRegistry == nil {
+		return errors.New("no CLA registry is configured")
+	}
+	// GitHub only links a commit to an account whose verified emails
+	// include the commit's, so the email can be trusted if it's linked.
+	email := commit.Author.Email
+	if commit.AuthorLogin == "" && !claUnlinkedEmails {
+		email = ""
+	}
+	signer, err := claRegistry.Signer(commit.AuthorLogin, email)
+	if err != nil {
+		return fmt.Errorf("looking up the CLA: %v", err)
+	}
+	if signer == "" {
+		return fmt.Errorf("%s is not covered by a CLA", claAuthor(commit))
+	}
+	return nil
+}
+
+// claAuthor names the author of a commit the way people will recognize
+// them.
+func claAuthor(commit *policy.Commit) string {
+	if commit.AuthorLogin != "" {
+		return "@" + commit.AuthorLogin
+	}
+	return fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+}
+
+// claRuleFor returns the CLA rule for a repo config, or nil if it doesn't
+// require a CLA.
+func claRuleFor(cfg *repoConfig) *claRule {
+	for _, rule := range cfg.rules {
+		if r, ok := rule.(*claRule); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// unsignedAuthors returns the authors of the commits that failed the CLA
+// check, each listed once.
+func unsignedAuthors(results []commitResult) []string {
+	authors := []string{}
+	seen := map[string]bool{}
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		author := claAuthor(policyCommit(result.Commit))
+		if !seen[strings.ToLower(author)] {
+			seen[strings.ToLower(author)] = true
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exit codes of the check and validate-config commands.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: sign-off-checker <command> [flags]
+
+Commands:
+  serve            Serve GitHub webhooks. This is the default.
+  check            Check the commits of a PR or a local revision range.
+  validate-config  Check a server config file or repo config files.
+  version          Print the version.
+
+Run "sign-off-checker <command> -h" for the flags of a command.
+`)
+}
+
+// pullRequestRE matches PRs written as owner/repo#number.
+var pullRequestRE = regexp.MustCompile(`^([^/#\s]+)/([^/#\s]+)#(\d+)$`)
+
+// runCheck checks a PR, or a range of commits in a local git repo, without
+// reporting the results to GitHub, and returns the exit code.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: sign-off-checker check [flags] owner/repo#number")
+		fmt.Fprintln(os.Stderr, "       sign-off-checker check [flags] [revision-range]")
+		fmt.Fprintln(os.Stderr, "\nThe revision range defaults to @{upstream}..HEAD.")
+		fs.PrintDefaults()
+	}
+	set := newSettings(fs, githubSettings, claSettings)
+	dir := fs.String("C", ".", "Git repo to check a revision range in.")
+	verbose := fs.Bool("v", false, "Log GitHub API calls and per-commit failures.")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	target := "@{upstream}..HEAD"
+	if fs.NArg() == 1 {
+		target = fs.Arg(0)
+	}
+
+	var results []commitResult
+	err := set.resolve()
+	if err == nil {
+		claRegistry, err = newCLARegistry(set)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if m := pullRequestRE.FindStringSubmatch(target); m != nil {
+		number, _ := strconv.Atoi(m[3])
+		results, err = checkRemotePullRequest(set, m[1], m[2], number)
+	} else {
+		claUnlinkedEmails = true
+		results, err = checkLocalRange(*dir, target)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	printResults(os.Stdout, results)
+	if len(unsignedSHAs(results)) > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// checkRemotePullRequest checks a PR on GitHub with the config from its
+// base branch.
+func checkRemotePullRequest(set *settings, owner, repo string, number int) ([]commitResult, error) {
+	name := fmt.Sprintf("%s/%s#%d", owner, repo, number)
+	if err := setupGitHub(set); err != nil {
+		return nil, err
+	}
+	client, err := clientForRepo(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := getPullRequest(client, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %v", name, err)
+	}
+
+	cfg := loadRepoConfig(client, owner, repo, pr.Base.GetSHA())
+	commits, err := listPullRequestCommits(client, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("getting commits of %s: %v", name, err)
+	}
+	return combineResults(checkCommits(client, name, commits, cfg)), nil
+}
+
+// checkLocalRange checks commits in a local git repo with the config from
+// its working tree. Without a client, exemptions by org or team membership
+// don't apply, and neither do exemptions by login since local commits
+// aren't linked to GitHub accounts.
+func checkLocalRange(dir, revRange string) ([]commitResult, error) {
+	cfg, err := localRepoConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := localCommits(dir, revRange)
+	if err != nil {
+		return nil, err
+	}
+	return combineResults(checkCommits(nil, revRange, commits, cfg)), nil
+}
+
+// printResults writes a report of each commit followed by a summary.
+func printResults(w io.Writer, results []commitResult) {
+	failed := 0
+	for _, result := range results {
+		commit := result.Commit
+		subject := ""
+		if commit.Commit != nil {
+			subject = strings.SplitN(commit.Commit.GetMessage(), "\n", 2)[0]
+		}
+		switch {
+		case result.Exempt != "":
+			fmt.Fprintf(w, "%s exempt  %s\n        %s\n", shortSHA(commit.GetSHA()), subject, result.Exempt)
+		case result.Err != nil:
+			fmt.Fprintf(w, "%s FAIL    %s\n        %v\n", shortSHA(commit.GetSHA()), subject, result.Err)
+			failed++
+		default:
+			fmt.Fprintf(w, "%s ok      %s\n", shortSHA(commit.GetSHA()), subject)
+		}
+	}
+	fmt.Fprintf(w, "%d of %d commits failed\n", failed, len(results))
+}
+
+// runValidateConfig checks config files and returns the exit code. The
+// server config file is given with -config, and repo config files as
+// arguments. With neither, the repo config in the current directory is
+// checked.
+func runValidateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sign-off-checker validate-config [-config server.json] [%s ...]\n", repoConfigPath)
+		fs.PrintDefaults()
+	}
+	set := newSettings(fs, serveSettings, githubSettings, claSettings, notifySettings)
+	fs.Parse(args)
+
+	files := fs.Args()
+	if *set.configFile == "" && len(files) == 0 {
+		files = []string{repoConfigPath}
+	}
+
+	code := exitOK
+	report := func(name string, err error) {
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			code = exitFailed
+			return
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+
+	if *set.configFile != "" {
+		report(*set.configFile, validateServerConfig(set))
+	}
+	for _, file := range files {
+		report(file, validateRepoConfigFile(file))
+	}
+	return code
+}
+
+// validateServerConfig resolves the serve settings, which reads the server
+// config file, and checks that every value is usable.
+func validateServerConfig(set *settings) error {
+	if err := set.resolve(); err != nil {
+		return err
+	}
+	if _, err := serverOptionsFrom(set); err != nil {
+		return err
+	}
+	if err := setupURLs(set); err != nil {
+		return err
+	}
+	if _, err := loadWebhookSecrets(set.String("webhook-secrets-file"), splitList(set.String("shared-secret"))); err != nil {
+		return err
+	}
+	if _, err := newDeliveryStore(set); err != nil {
+		return err
+	}
+	if _, err := newReporter(set.String("reporter"), set.String("github-app-id") != ""); err != nil {
+		return err
+	}
+	if _, err := newCLARegistry(set); err != nil {
+		return err
+	}
+	_, _, err := queueSettings(set)
+	return err
+}
+
+// validateRepoConfigFile checks a repo config file more strictly than when
+// it is loaded from a repo: unknown fields are errors rather than ignored.
+func validateRepoConfigFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the config object")
+	}
+	override := repoConfig{}
+	if err := json.Unmarshal(raw, &override); err != nil {
+		return err
+	}
+	if err := checkFields("", raw, reflect.TypeOf(override)); err != nil {
+		return err
+	}
+	cfg := defaultRepoConfig("owner", "repo")
+	cfg.merge(&override)
+	return cfg.validate()
+}
+
+// checkFields returns an error naming the first key in data, which has
+// already been decoded into a value of type t, that isn't a field of t. It
+// looks into nested objects and arrays of objects. This does what
+// json.Decoder.DisallowUnknownFields does in Go 1.10 and later.
+func checkFields(path string, data json.RawMessage, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			// null, which leaves the field alone.
+			return nil
+		}
+		keys := []string{}
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			field, ok := jsonField(t, key)
+			if !ok {
+				return fmt.Errorf("unknown field %q", name)
+			}
+			if err := checkFields(name, obj[key], field.Type); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list := []json.RawMessage{}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil
+		}
+		for i, elem := range list {
+			if err := checkFields(fmt.Sprintf("%s[%d]", path, i), elem, t.Elem()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonField returns the field of struct type t that encoding/json decodes
+// key into. Like encoding/json, it matches names without regard to case.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCheckFields(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{data: `{"context": "dco", "requiredTrailers": ["Signed-off-by"]}`},
+		{data: `{"Context": "dco", "HELPURL": "https://example.com/"}`},
+		{data: `{"cla": null, "rules": null}`},
+		{data: `{"rules": [{"type": "subject-length", "max": 50}], "exempt": {"bots": true}}`},
+		{data: `{"contxt": "dco"}`, err: `unknown field "contxt"`},
+		{data: `{"exempt": {"bot": true}}`, err: `unknown field "exempt.bot"`},
+		{data: `{"rules": [{"type": "dco"}, {"type": "subject-length", "maximum": 50}]}`, err: `unknown field "rules[1].maximum"`},
+		{data: `{"rule": [], "cla": {"onl": true}}`, err: `unknown field "cla.onl"`},
+	}
+	for _, tt := range tests {
+		err := checkFields("", json.RawMessage(tt.data), reflect.TypeOf(repoConfig{}))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("checkFields(%s) = %q, want %q", tt.data, got, tt.err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/heptio/sign-off-checker/pkg/ghapp"
+	"github.com/heptio/sign-off-checker/pkg/policy"
+	"golang.org/x/oauth2"
+)
+
+// Exactly one of tokenClient and app is set, depending on whether we run
+// with a personal access token or as a GitHub App.
+var tokenClient *github.Client
+var app *ghapp.App
+
+var appClientsMu sync.Mutex
+var appClients = map[int]*github.Client{}
+
+// tokenLogin caches the login of the user the personal access token belongs
+// to.
+var tokenLoginMu sync.Mutex
+var tokenLogin string
+
+// The GitHub API and upload endpoints, and the web UI that links point to.
+// These are changed to use GitHub Enterprise.
+var apiURL, _ = url.Parse("https://api.github.com/")
+var uploadURL, _ = url.Parse("https://uploads.github.com/")
+var webURL = "https://github.com/"
+
+func setupTokenClient(token string) {
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	tc := oauth2.NewClient(oauth2.NoContext, ts)
+	tokenClient = newGitHubClient(tc)
+}
+
+// newGitHubClient returns a client for the configured GitHub.
+func newGitHubClient(httpClient *http.Client) *github.Client {
+	client := github.NewClient(httpClient)
+	client.BaseURL = apiURL
+	client.UploadURL = uploadURL
+	return client
+}
+
+// setupURLs sets the GitHub URLs from settings. Each URL must be absolute;
+// a trailing slash is added if it is missing.
+func setupURLs(set *settings) error {
+	var err error
+	if apiURL, err = baseURL("github-api-url", set.String("github-api-url")); err != nil {
+		return err
+	}
+	if uploadURL, err = baseURL("github-upload-url", set.String("github-upload-url")); err != nil {
+		return err
+	}
+	web, err := baseURL("github-web-url", set.String("github-web-url"))
+	if err != nil {
+		return err
+	}
+	webURL = web.String()
+	return nil
+}
+
+// noreplyDomain returns the domain of the noreply email addresses of the
+// configured GitHub, which is users.noreply.<host> of its web UI.
+func noreplyDomain() string {
+	u, err := url.Parse(webURL)
+	if err != nil || u.Hostname() == "" {
+		return policy.DefaultNoreplyDomain
+	}
+	return "users.noreply." + u.Hostname()
+}
+
+func baseURL(name, value string) (*url.URL, error) {
+	if !strings.HasSuffix(value, "/") {
+		value += "/"
+	}
+	u, err := url.Parse(value)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("%s must be an absolute URL, not %q", name, value)
+	}
+	return u, nil
+}
+
+// setupGitHub sets up the GitHub App if an app ID is configured, and the
+// personal access token client otherwise.
+func setupGitHub(set *settings) error {
+	if err := setupURLs(set); err != nil {
+		return err
+	}
+	if appID := set.String("github-app-id"); appID != "" {
+		keyFile := set.String("github-app-private-key")
+		if keyFile == "" {
+			return errors.New("GITHUB_APP_PRIVATE_KEY is not set")
+		}
+		if err := setupApp(appID, keyFile); err != nil {
+			return fmt.Errorf("loading GitHub App: %v", err)
+		}
+		return nil
+	}
+	token := set.String("github-token")
+	if token == "" {
+		return errors.New("GITHUB_TOKEN or GITHUB_APP_ID must be set")
+	}
+	setupTokenClient(token)
+	return nil
+}
+
+func setupApp(appID, keyFile string) error {
+	id, err := strconv.Atoi(appID)
+	if err != nil {
+		return errors.New("GITHUB_APP_ID must be a number")
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	app, err = ghapp.New(id, key)
+	if err != nil {
+		return err
+	}
+	app.BaseURL = apiURL
+	return nil
+}
+
+// clientFor returns the client to act on behalf of the app installation that
+// sent an event. With a personal access token the installation is ignored.
+func clientFor(installation *github.Installation) (*github.Client, error) {
+	if app == nil {
+		return tokenClient, nil
+	}
+	if installation == nil || installation.ID == nil {
+		return nil, errors.New("event is not from a GitHub App installation")
+	}
+
+	appClientsMu.Lock()
+	defer appClientsMu.Unlock()
+	c, ok := appClients[*installation.ID]
+	if !ok {
+		// oauth2.NewClient would reuse tokens until shortly before they
+		// expire, rather than leaving that to the app's token cache.
+		transport := &oauth2.Transport{Source: app.TokenSource(*installation.ID)}
+		c = newGitHubClient(&http.Client{Transport: transport})
+		appClients[*installation.ID] = c
+	}
+	return c, nil
+}
+
+// clientForRepo returns a client that can act on a repo, for when there is
+// no event saying which installation to use.
+func clientForRepo(owner, repo string) (*github.Client, error) {
+	if app == nil {
+		return tokenClient, nil
+	}
+	id, err := app.RepoInstallation(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return clientFor(&github.Installation{ID: &id})
+}
+
+// botLogin returns the login that our comments are attributed to: the app's
+// bot account, or the user the personal access token belongs to.
+func botLogin(client *github.Client) (string, error) {
+	if app != nil {
+		return app.BotLogin()
+	}
+
+	tokenLoginMu.Lock()
+	defer tokenLoginMu.Unlock()
+	if tokenLogin != "" {
+		return tokenLogin, nil
+	}
+	var user *github.User
+	err := callGitHub("Users.Get", "Getting the authenticated user", func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		user, resp, err = client.Users.Get(context.TODO(), "")
+		return resp, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if user.GetLogin() == "" {
+		return "", errors.New("authenticated user has no login")
+	}
+	tokenLogin = user.GetLogin()
+	return tokenLogin, nil
+}
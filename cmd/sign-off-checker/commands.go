@@ -0,0 +1,78 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Commands that can be given in PR comments.
+const (
+	recheckCommand  = "/recheck-signoff"
+	overrideCommand = "/signoff-override"
+)
+
+// parseCommand returns the first line of a comment that starts with a slash
+// command, split into the command and the rest of the line.
+func parseCommand(body string) (command, args string) {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 {
+			args = strings.TrimSpace(parts[1])
+		}
+		return parts[0], args
+	}
+	return "", ""
+}
+
+// HandleIssueComment acts on slash commands in new comments on PRs.
+func HandleIssueComment(event *github.IssueCommentEvent) error {
+	if event.GetAction() != "created" || event.Issue == nil || event.Issue.PullRequestLinks == nil ||
+		event.Comment == nil || event.Repo == nil || event.Repo.Owner == nil {
+		return nil
+	}
+	// Ignore our own comments, and those of other bots.
+	if event.Comment.User != nil && event.Comment.User.GetType() == "Bot" {
+		return nil
+	}
+
+	command, args := parseCommand(event.Comment.GetBody())
+	switch command {
+	case recheckCommand:
+		log.Printf("%s asked for %s#%d to be checked again", event.Comment.User.GetLogin(), event.Repo.GetFullName(), event.Issue.GetNumber())
+		// Comment events don't say which commits the PR has, so the check
+		// looks them up.
+		return enqueueCheck(event.Installation, event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.Issue.GetNumber(), "", "")
+	case overrideCommand:
+		owner, repo, number := event.Repo.Owner.GetLogin(), event.Repo.GetName(), event.Issue.GetNumber()
+		login := event.Comment.User.GetLogin()
+		// The override is for the head commit the maintainer checked, which
+		// they name in the command.
+		sha, reason := parseOverride(args)
+		return enqueueOverride(event.Installation, owner, repo, number, func(client *github.Client) {
+			OverridePullRequest(client, owner, repo, number, sha, login, reason)
+		})
+	}
+	return nil
+}
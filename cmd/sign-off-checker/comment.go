@@ -0,0 +1,287 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/heptio/sign-off-checker/pkg/policy"
+)
+
+// commentMarker is hidden in the body of the comment we leave on a PR so
+// that later runs can find it and update it in place.
+const commentMarker = "<!-- sign-off-checker -->"
+
+// UpdateComment makes sure the PR has a comment explaining any missing
+// sign-offs. An existing comment is edited rather than a new one being
+// posted, and once every commit is signed off it is changed to say so.
+func UpdateComment(client *github.Client, owner, repo string, number int, results []commitResult, cfg *repoConfig) {
+	existing, err := findComment(client, owner, repo, number, commentMarker)
+	if err != nil {
+		log.Printf("Error listing comments for PR: %v", err)
+		return
+	}
+
+	failed := false
+	for _, result := range results {
+		if result.Err != nil {
+			failed = true
+			break
+		}
+	}
+	if !failed && existing == nil {
+		return
+	}
+
+	var body string
+	if failed {
+		body = failureComment(results, cfg)
+	} else {
+		body = successComment(cfg)
+	}
+
+	desc := fmt.Sprintf("Commenting on %s/%s#%d", owner, repo, number)
+	if existing == nil {
+		err = createComment(client, owner, repo, number, body, commentMarker)
+	} else if existing.GetBody() != body {
+		err = callGitHub("Issues.EditComment", desc, func() (*github.Response, error) {
+			_, resp, err := client.Issues.EditComment(context.TODO(), owner, repo, existing.GetID(), &github.IssueComment{Body: &body})
+			return resp, err
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating comment on PR: %v", err)
+	}
+}
+
+// createComment posts a comment on a PR. marker must be in body, and in none
+// of our other comments on the PR, so that the comment can be found if
+// GitHub fails after posting it.
+func createComment(client *github.Client, owner, repo string, number int, body, marker string) error {
+	created := func() (bool, error) {
+		comment, err := findComment(client, owner, repo, number, marker)
+		return comment != nil, err
+	}
+	return createOnGitHub("Issues.CreateComment", fmt.Sprintf("Commenting on %s/%s#%d", owner, repo, number), func() (*github.Response, error) {
+		_, resp, err := client.Issues.CreateComment(context.TODO(), owner, repo, number, &github.IssueComment{Body: &body})
+		return resp, err
+	}, created)
+}
+
+// findComment returns our comment on the PR that contains marker, or nil if
+// there isn't one. Only our own comments count, so that a comment quoting
+// the marker isn't mistaken for ours.
+func findComment(client *github.Client, owner, repo string, number int, marker string) (*github.IssueComment, error) {
+	login, err := botLogin(client)
+	if err != nil {
+		return nil, fmt.Errorf("getting our own login: %v", err)
+	}
+	opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var comments []*github.IssueComment
+		var resp *github.Response
+		err := callGitHub("Issues.ListComments", fmt.Sprintf("Listing comments on %s/%s#%d", owner, repo, number), func() (*github.Response, error) {
+			var err error
+			comments, resp, err = client.Issues.ListComments(context.TODO(), owner, repo, number, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			if isOwnComment(comment, login, marker) {
+				return comment, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// isOwnComment reports whether comment was written by login and contains
+// marker.
+func isOwnComment(comment *github.IssueComment, login, marker string) bool {
+	if comment.User == nil || !strings.EqualFold(comment.User.GetLogin(), login) {
+		return false
+	}
+	return strings.Contains(comment.GetBody(), marker)
+}
+
+func failureComment(results []commitResult, cfg *repoConfig) string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, commentMarker)
+	if onlyTrailers(cfg) {
+		fmt.Fprintf(&b, "The following commits in this PR are missing %s:\n", trailerList(cfg))
+	} else {
+		fmt.Fprintln(&b, "The following commits in this PR don't follow this repo's rules for commit messages:")
+	}
+	fmt.Fprintln(&b)
+	failureTable(&b, results)
+	fmt.Fprintln(&b)
+	if requiresSignOff(cfg) {
+		signOffHelp(&b, len(results))
+	}
+	if usesDCO(cfg) {
+		dcoHelp(&b)
+	}
+	if rule := claRuleFor(cfg); rule != nil {
+		fmt.Fprintf(&b, "Authors who aren't covered by a CLA can sign one at %s, then comment `%s` to check again.\n", rule.url, recheckCommand)
+		fmt.Fprintln(&b)
+	}
+	if cfg.Messages.Comment != "" {
+		fmt.Fprintln(&b, cfg.Messages.Comment)
+		fmt.Fprintln(&b)
+	}
+	fmt.Fprintf(&b, "See %s for more details.\n", cfg.HelpURL)
+	return b.String()
+}
+
+// failureTable writes a table of the commits that failed and why.
+func failureTable(b *bytes.Buffer, results []commitResult) {
+	fmt.Fprintln(b, "| Commit | Author | Problem |")
+	fmt.Fprintln(b, "| --- | --- | --- |")
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		fmt.Fprintf(b, "| %s | %s | %s |\n",
+			shortSHA(result.Commit.GetSHA()),
+			escapeCell(commitAuthor(result.Commit)),
+			escapeCell(result.Err.Error()))
+	}
+}
+
+// signOffHelp explains how to add missing Signed-off-by lines.
+func signOffHelp(b *bytes.Buffer, commits int) {
+	fmt.Fprintln(b, "Each commit needs a line of the form `Signed-off-by: Your Name <your@email>` matching its author.")
+	fmt.Fprintln(b, "If only the most recent commit is affected you can fix it with:")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "```")
+	fmt.Fprintln(b, "git commit --amend -s --no-edit")
+	fmt.Fprintln(b, "git push --force-with-lease")
+	fmt.Fprintln(b, "```")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "To sign off every commit in this PR:")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "```")
+	fmt.Fprintf(b, "git rebase --signoff HEAD~%d\n", commits)
+	fmt.Fprintln(b, "git push --force-with-lease")
+	fmt.Fprintln(b, "```")
+	fmt.Fprintln(b)
+}
+
+// dcoHelp explains the parts of a DCO check that go beyond a Signed-off-by
+// line for the author.
+func dcoHelp(b *bytes.Buffer) {
+	fmt.Fprintln(b, "Everyone named in a `Co-authored-by` line must also sign off the commit.")
+	fmt.Fprintln(b, "If a commit can't be rewritten, its author can sign it off after the fact by pushing a commit with a line like this for each commit, and signing that commit off too:")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "```")
+	fmt.Fprintln(b, "I, Your Name <your@email>, hereby add my Signed-off-by to this commit: <sha>")
+	fmt.Fprintln(b, "```")
+	fmt.Fprintln(b)
+}
+
+func successComment(cfg *repoConfig) string {
+	if !onlyTrailers(cfg) {
+		return fmt.Sprintf("%s\nAll commits in this PR now follow this repo's rules for commit messages. Thanks!\n", commentMarker)
+	}
+	return fmt.Sprintf("%s\nAll commits in this PR now have %s. Thanks!\n", commentMarker, trailerList(cfg))
+}
+
+// onlyTrailers reports whether every rule in cfg requires trailers.
+func onlyTrailers(cfg *repoConfig) bool {
+	for _, rule := range cfg.rules {
+		switch rule := rule.(type) {
+		case *policy.Trailers:
+			if len(rule.Required) == 0 {
+				return false
+			}
+		case *policy.DCO:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// trailerList describes the trailers required by cfg for humans.
+func trailerList(cfg *repoConfig) string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, rule := range cfg.rules {
+		var required []string
+		switch rule := rule.(type) {
+		case *policy.Trailers:
+			required = rule.Required
+		case *policy.DCO:
+			required = []string{policy.SignedOffBy}
+		}
+		for _, trailer := range required {
+			if !seen[strings.ToLower(trailer)] {
+				seen[strings.ToLower(trailer)] = true
+				names = append(names, "`"+trailer+"`")
+			}
+		}
+	}
+	if len(names) == 1 {
+		return "a valid " + names[0] + " line"
+	}
+	return "valid " + strings.Join(names, ", ") + " lines"
+}
+
+func requiresSignOff(cfg *repoConfig) bool {
+	for _, rule := range cfg.rules {
+		if r, ok := rule.(interface{ RequiresSignOff() bool }); ok && r.RequiresSignOff() {
+			return true
+		}
+	}
+	return false
+}
+
+func usesDCO(cfg *repoConfig) bool {
+	for _, rule := range cfg.rules {
+		if _, ok := rule.(*policy.DCO); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commitAuthor describes the author of a commit for humans.
+func commitAuthor(commit *github.RepositoryCommit) string {
+	var desc string
+	if commit.Commit != nil {
+		author := commit.Commit.Author
+		desc = fmt.Sprintf("%s <%s>", author.GetName(), author.GetEmail())
+	}
+	if commit.Author != nil && commit.Author.GetLogin() != "" {
+		desc = fmt.Sprintf("@%s (%s)", commit.Author.GetLogin(), desc)
+	}
+	return desc
+}
+
+func escapeCell(str string) string {
+	return strings.Replace(str, "|", "\\|", -1)
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/heptio/sign-off-checker/pkg/policy"
+)
+
+// repoConfigPath is where a repo can override our behavior. It is read from
+// the base branch of each PR so that a PR can't change its own rules.
+const repoConfigPath = ".github/sign-off-checker.json"
+
+// maxCachedConfigs bounds the number of repo configs kept in memory.
+const maxCachedConfigs = 1000
+
+// repoConfig is the per-repo configuration read from repoConfigPath. Fields
+// left empty in the file take their defaults from defaultRepoConfig.
+type repoConfig struct {
+	// Context is the name of the commit status or check run.
+	Context string `json:"context"`
+
+	// HelpURL is linked from the status and the PR comment.
+	HelpURL string `json:"helpURL"`
+
+	// RequiredTrailers lists the trailers every commit must have. A
+	// Signed-off-by trailer must also name the author or committer. It is
+	// shorthand for a single "trailers" rule reported as Context, and is
+	// ignored if Rules is set.
+	RequiredTrailers []string `json:"requiredTrailers"`
+
+	// Rules are the rules every commit is checked against, each reported
+	// under its own name. A "trailers" rule is named Context by default.
+	Rules []policy.Config `json:"rules"`
+
+	// CLA requires commit authors to be covered by a signed CLA, reported
+	// as a rule of its own.
+	CLA claConfig `json:"cla"`
+
+	// Push selects the branches whose pushes are checked.
+	Push pushConfig `json:"push"`
+
+	// Exempt lists commits that aren't checked against any of the rules.
+	Exempt exemptions `json:"exempt"`
+
+	// Messages are used when reporting the rule named Context.
+	Messages messages `json:"messages"`
+
+	// rules are built from Rules or RequiredTrailers by validate.
+	rules []policy.Rule
+
+	// rule is the rule being reported, in configs returned by forRule.
+	rule policy.Rule
+}
+
+// exemptions selects commits that are not checked, by their author.
+type exemptions struct {
+	// Logins are GitHub logins.
+	Logins []string `json:"logins"`
+
+	// Emails are patterns such as "*@example.com" matched against the
+	// commit's author email. The syntax is that of path.Match.
+	Emails []string `json:"emails"`
+
+	// Bots exempts GitHub accounts of type Bot.
+	Bots bool `json:"bots"`
+
+	// Orgs are organizations whose members are exempt.
+	Orgs []string `json:"orgs"`
+
+	// Teams are teams, written "org/team-slug", whose members are exempt.
+	Teams []string `json:"teams"`
+}
+
+// messages overrides the text we show to contributors.
+type messages struct {
+	// Success is the status description on the PR head when every commit
+	// passes.
+	Success string `json:"success"`
+
+	// Failure is the status description on the PR head when some commits
+	// fail. The short SHAs of those commits are appended.
+	Failure string `json:"failure"`
+
+	// Comment is added to the end of the comment left on failing PRs.
+	Comment string `json:"comment"`
+}
+
+func defaultRepoConfig(owner, repo string) *repoConfig {
+	cfg := &repoConfig{
+		Context:          "signed-off-by",
+		HelpURL:          fmt.Sprintf("%s%s/%s/blob/master/CONTRIBUTING.md", webURL, owner, repo),
+		RequiredTrailers: []string{policy.SignedOffBy},
+		Messages: messages{
+			Success: "All commits in PR have Signed-off-by",
+			Failure: "Missing valid Signed-off-by on",
+		},
+	}
+	// The defaults are always valid; this builds the rules.
+	cfg.validate()
+	return cfg
+}
+
+var configCacheMu sync.Mutex
+var configCache = map[string]*repoConfig{}
+
+// loadRepoConfig returns the config for a repo as of the given commit on its
+// base branch. If the repo has no config file, or it can't be fetched or
+// parsed, the defaults are used.
+func loadRepoConfig(client *github.Client, owner, repo, baseSHA string) *repoConfig {
+	key := fmt.Sprintf("%s/%s@%s", owner, repo, baseSHA)
+	if baseSHA != "" {
+		configCacheMu.Lock()
+		cfg, ok := configCache[key]
+		configCacheMu.Unlock()
+		if ok {
+			return cfg
+		}
+	}
+
+	cfg, err := fetchRepoConfig(client, owner, repo, baseSHA)
+	if err != nil {
+		log.Printf("Error loading %s from %s/%s, using defaults: %v", repoConfigPath, owner, repo, err)
+		// Don't cache failures so a transient error doesn't stick.
+		return defaultRepoConfig(owner, repo)
+	}
+
+	if baseSHA != "" {
+		configCacheMu.Lock()
+		if len(configCache) >= maxCachedConfigs {
+			configCache = map[string]*repoConfig{}
+		}
+		configCache[key] = cfg
+		configCacheMu.Unlock()
+	}
+	return cfg
+}
+
+func fetchRepoConfig(client *github.Client, owner, repo, ref string) (*repoConfig, error) {
+	var file *github.RepositoryContent
+	var resp *github.Response
+	err := callGitHub("Repositories.GetContents", fmt.Sprintf("Getting %s from %s/%s", repoConfigPath, owner, repo), func() (*github.Response, error) {
+		var err error
+		file, _, resp, err = client.Repositories.GetContents(context.TODO(), owner, repo, repoConfigPath,
+			&github.RepositoryContentGetOptions{Ref: ref})
+		return resp, err
+	})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return defaultRepoConfig(owner, repo), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is not a file", repoConfigPath)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return parseRepoConfig(owner, repo, []byte(content))
+}
+
+// parseRepoConfig parses the contents of a repo config file and fills in
+// defaults for the fields it leaves empty.
+func parseRepoConfig(owner, repo string, data []byte) (*repoConfig, error) {
+	override := repoConfig{}
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", repoConfigPath, err)
+	}
+	cfg := defaultRepoConfig(owner, repo)
+	cfg.merge(&override)
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", repoConfigPath, err)
+	}
+	return cfg, nil
+}
+
+// validate checks for settings that are well formed JSON but can't work,
+// and builds the rules.
+func (cfg *repoConfig) validate() error {
+	if len(cfg.Rules) == 0 {
+		rule, err := policy.NewTrailers(cfg.Context, cfg.RequiredTrailers, nil)
+		if err != nil {
+			return fmt.Errorf("requiredTrailers: %v", err)
+		}
+		cfg.rules = []policy.Rule{rule}
+	} else {
+		configs := append([]policy.Config(nil), cfg.Rules...)
+		for i := range configs {
+			isSignOff := configs[i].Type == policy.TypeTrailers || configs[i].Type == policy.TypeDCO
+			if isSignOff && configs[i].Name == "" {
+				configs[i].Name = cfg.Context
+			}
+			configs[i].NoreplyDomain = noreplyDomain()
+		}
+		rules, err := policy.NewSet(configs)
+		if err != nil {
+			return fmt.Errorf("rules: %v", err)
+		}
+		cfg.rules = rules
+	}
+	if cfg.CLA.URL != "" {
+		name := cfg.CLA.Context
+		if name == "" {
+			name = "cla"
+		}
+		rule := &claRule{name: name, url: cfg.CLA.URL}
+		if cfg.CLA.Only {
+			cfg.rules = nil
+		}
+		for _, other := range cfg.rules {
+			if other.Name() == name {
+				return fmt.Errorf("cla: there is already a rule named %q", name)
+			}
+		}
+		cfg.rules = append(cfg.rules, rule)
+	} else if cfg.CLA.Only {
+		return fmt.Errorf("cla: only is set but url isn't")
+	}
+
+	for _, pattern := range cfg.Exempt.Emails {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("exempt.emails: bad pattern %q", pattern)
+		}
+	}
+	for _, pattern := range cfg.Push.Branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("push.branches: bad pattern %q: %v", pattern, err)
+		}
+	}
+	for _, team := range cfg.Exempt.Teams {
+		if parts := strings.Split(team, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("exempt.teams: %q is not of the form org/team-slug", team)
+		}
+	}
+	return nil
+}
+
+// merge sets every field that is set in override.
+func (cfg *repoConfig) merge(override *repoConfig) {
+	if override.Context != "" {
+		cfg.Context = override.Context
+	}
+	if override.HelpURL != "" {
+		cfg.HelpURL = override.HelpURL
+	}
+	if len(override.RequiredTrailers) > 0 {
+		cfg.RequiredTrailers = override.RequiredTrailers
+	}
+	if len(override.Rules) > 0 {
+		cfg.Rules = override.Rules
+	}
+	cfg.CLA = override.CLA
+	cfg.Push = override.Push
+	cfg.Exempt = override.Exempt
+	if override.Messages.Success != "" {
+		cfg.Messages.Success = override.Messages.Success
+	}
+	if override.Messages.Failure != "" {
+		cfg.Messages.Failure = override.Messages.Failure
+	}
+	cfg.Messages.Comment = override.Messages.Comment
+}
+
+// forRule returns the config for reporting a single rule. The rule named
+// Context is reported with Messages, and other rules with messages derived
+// from their descriptions.
+func (cfg *repoConfig) forRule(rule policy.Rule) *repoConfig {
+	ruleCfg := *cfg
+	ruleCfg.rule = rule
+	if r, ok := rule.(*claRule); ok {
+		ruleCfg.Context = r.name
+		ruleCfg.HelpURL = r.url
+		ruleCfg.Messages.Success = "All commit authors are covered by a CLA"
+		ruleCfg.Messages.Failure = "No CLA signed by"
+	} else if rule.Name() != cfg.Context {
+		ruleCfg.Context = rule.Name()
+		ruleCfg.Messages.Success = "Every commit " + rule.Description()
+		ruleCfg.Messages.Failure = rule.Name() + " failed on"
+	}
+	return &ruleCfg
+}
+
+// failures lists what failed for the status on the head commit: the
+// authors who haven't signed for the CLA, and the short SHAs of the failing
+// commits otherwise.
+func (cfg *repoConfig) failures(results []commitResult) []string {
+	if _, ok := cfg.rule.(*claRule); ok {
+		return unsignedAuthors(results)
+	}
+	return unsignedSHAs(results)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/heptio/sign-off-checker/pkg/policy"
+)
+
+func TestParseRepoConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		rules []string
+		err   string
+	}{
+		{name: "defaults", data: `{}`, rules: []string{"signed-off-by"}},
+		{name: "context names the trailers rule", data: `{"context": "dco"}`, rules: []string{"dco"}},
+		{name: "required trailers", data: `{"requiredTrailers": ["Signed-off-by", "Reviewed-by"]}`, rules: []string{"signed-off-by"}},
+		{name: "bad trailer", data: `{"requiredTrailers": ["Signed off by"]}`, err: "requiredTrailers:"},
+		{
+			name:  "rules replace required trailers",
+			data:  `{"requiredTrailers": ["Reviewed-by"], "rules": [{"type": "subject-length", "max": 50}]}`,
+			rules: []string{"subject-length"},
+		},
+		{
+			name:  "sign-off rules are named after context",
+			data:  `{"context": "sign-off", "rules": [{"type": "dco"}, {"type": "trailers", "name": "reviewed", "trailers": ["Reviewed-by"]}]}`,
+			rules: []string{"sign-off", "reviewed"},
+		},
+		{name: "bad rule", data: `{"rules": [{"type": "nope"}]}`, err: "rules:"},
+		{name: "cla", data: `{"cla": {"url": "https://example.com/cla"}}`, rules: []string{"signed-off-by", "cla"}},
+		{name: "cla context", data: `{"cla": {"url": "https://example.com/cla", "context": "license"}}`, rules: []string{"signed-off-by", "license"}},
+		{name: "cla only", data: `{"requiredTrailers": ["Reviewed-by"], "cla": {"url": "https://example.com/cla", "only": true}}`, rules: []string{"cla"}},
+		{name: "cla only without url", data: `{"cla": {"only": true}}`, err: "cla: only is set but url isn't"},
+		{name: "cla name taken", data: `{"context": "cla", "cla": {"url": "https://example.com/cla"}}`, err: `cla: there is already a rule named "cla"`},
+		{name: "bad exempt email", data: `{"exempt": {"emails": ["[a-"]}}`, err: "exempt.emails: bad pattern"},
+		{name: "bad exempt team", data: `{"exempt": {"teams": ["heptio"]}}`, err: "exempt.teams:"},
+		{name: "empty exempt team", data: `{"exempt": {"teams": ["heptio/"]}}`, err: "exempt.teams:"},
+		{name: "bad push branch", data: `{"push": {"branches": ["release-["]}}`, err: "push.branches: bad pattern"},
+		{name: "not json", data: `{"context":`, err: "parsing " + repoConfigPath},
+	}
+	for _, tt := range tests {
+		cfg, err := parseRepoConfig("o", "r", []byte(tt.data))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		names := []string{}
+		for _, rule := range cfg.rules {
+			names = append(names, rule.Name())
+		}
+		if !reflect.DeepEqual(names, tt.rules) {
+			t.Errorf("%s: got rules %q, want %q", tt.name, names, tt.rules)
+		}
+	}
+}
+
+func TestParseRepoConfigRequiredTrailers(t *testing.T) {
+	cfg, err := parseRepoConfig("o", "r", []byte(`{"requiredTrailers": ["Signed-off-by", "Reviewed-by"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := cfg.rules[0].(*policy.Trailers)
+	if !ok {
+		t.Fatalf("got rule %T, want *policy.Trailers", cfg.rules[0])
+	}
+	if want := []string{"Signed-off-by", "Reviewed-by"}; !reflect.DeepEqual(rule.Required, want) {
+		t.Errorf("got required trailers %q, want %q", rule.Required, want)
+	}
+}
+
+func TestMergeRepoConfig(t *testing.T) {
+	cfg := defaultRepoConfig("o", "r")
+	cfg.merge(&repoConfig{
+		HelpURL:  "https://example.com/help",
+		Messages: messages{Failure: "Unsigned:", Comment: "Ask in #dev."},
+	})
+	if cfg.Context != "signed-off-by" {
+		t.Errorf("context = %q, want the default", cfg.Context)
+	}
+	if cfg.HelpURL != "https://example.com/help" {
+		t.Errorf("helpURL = %q, want the override", cfg.HelpURL)
+	}
+	if cfg.Messages.Success != "All commits in PR have Signed-off-by" {
+		t.Errorf("success message = %q, want the default", cfg.Messages.Success)
+	}
+	if cfg.Messages.Failure != "Unsigned:" || cfg.Messages.Comment != "Ask in #dev." {
+		t.Errorf("messages = %+v, want the overrides", cfg.Messages)
+	}
+	if !reflect.DeepEqual(cfg.RequiredTrailers, []string{policy.SignedOffBy}) {
+		t.Errorf("requiredTrailers = %q, want the default", cfg.RequiredTrailers)
+	}
+}
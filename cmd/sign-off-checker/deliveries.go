@@ -0,0 +1,116 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// deliveryStore remembers the IDs of webhook deliveries that have been
+// handled, so that redelivered or replayed webhooks are only acted on once.
+// Implementations must be safe for concurrent use.
+type deliveryStore interface {
+	// Seen records the delivery and reports whether it was already
+	// recorded.
+	Seen(id string) (bool, error)
+
+	// Forget removes a delivery, so that it is handled if it is delivered
+	// again.
+	Forget(id string) error
+}
+
+// newDeliveryStore returns the delivery store configured by the settings,
+// or nil if deliveries aren't to be remembered.
+func newDeliveryStore(set *settings) (deliveryStore, error) {
+	size, err := set.Int("delivery-cache-size")
+	if err != nil || size < 0 {
+		return nil, errors.New("delivery-cache-size must be a number, 0 or more")
+	}
+	ttl, err := set.Duration("delivery-cache-ttl")
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, errors.New("delivery-cache-ttl must be more than 0")
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return newMemoryDeliveryStore(size, ttl), nil
+}
+
+// memoryDeliveryStore keeps delivery IDs in memory for a while. Once it
+// holds size IDs the oldest are dropped, even if they haven't expired.
+type memoryDeliveryStore struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List // Of *delivery, oldest first.
+	byID  map[string]*list.Element
+}
+
+type delivery struct {
+	id      string
+	expires time.Time
+}
+
+func newMemoryDeliveryStore(size int, ttl time.Duration) *memoryDeliveryStore {
+	return &memoryDeliveryStore{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		byID:  map[string]*list.Element{},
+	}
+}
+
+func (m *memoryDeliveryStore) Seen(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := m.byID[id]; ok {
+		if now.Before(e.Value.(*delivery).expires) {
+			return true, nil
+		}
+		m.remove(e)
+	}
+
+	m.byID[id] = m.order.PushBack(&delivery{id: id, expires: now.Add(m.ttl)})
+	for m.order.Len() > m.size || (m.order.Len() > 0 && now.After(m.order.Front().Value.(*delivery).expires)) {
+		m.remove(m.order.Front())
+	}
+	return false, nil
+}
+
+func (m *memoryDeliveryStore) Forget(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.byID[id]; ok {
+		m.remove(e)
+	}
+	return nil
+}
+
+// remove drops an entry. m.mu must be held.
+func (m *memoryDeliveryStore) remove(e *list.Element) {
+	m.order.Remove(e)
+	delete(m.byID, e.Value.(*delivery).id)
+}
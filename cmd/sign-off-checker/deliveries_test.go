@@ -0,0 +1,145 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryDeliveryStore(t *testing.T) {
+	m := newMemoryDeliveryStore(10, time.Hour)
+	for _, step := range []struct {
+		id   string
+		seen bool
+	}{
+		{"a", false},
+		{"b", false},
+		{"a", true},
+		{"b", true},
+		{"c", false},
+	} {
+		if seen, err := m.Seen(step.id); err != nil || seen != step.seen {
+			t.Errorf("Seen(%q) = %v, %v, want %v", step.id, seen, err, step.seen)
+		}
+	}
+}
+
+func TestMemoryDeliveryStoreForget(t *testing.T) {
+	m := newMemoryDeliveryStore(10, time.Hour)
+	m.Seen("a")
+	m.Seen("b")
+	if err := m.Forget("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Forget("unknown"); err != nil {
+		t.Errorf("Forget of an unknown delivery returned %v", err)
+	}
+	if seen, _ := m.Seen("a"); seen {
+		t.Error("forgotten delivery was seen")
+	}
+	if seen, _ := m.Seen("b"); !seen {
+		t.Error("Forget dropped another delivery")
+	}
+	if seen, _ := m.Seen("a"); !seen {
+		t.Error("delivery wasn't recorded again after being forgotten")
+	}
+}
+
+func TestMemoryDeliveryStoreEvictsOldest(t *testing.T) {
+	m := newMemoryDeliveryStore(2, time.Hour)
+	m.Seen("a")
+	m.Seen("b")
+	m.Seen("c")
+	if m.order.Len() != 2 || len(m.byID) != 2 {
+		t.Errorf("store holds %d deliveries (%d by ID), want 2", m.order.Len(), len(m.byID))
+	}
+	// Checking these in the other order would record "a" again and evict
+	// "b".
+	if seen, _ := m.Seen("c"); !seen {
+		t.Error("newest delivery was evicted")
+	}
+	if seen, _ := m.Seen("b"); !seen {
+		t.Error("second delivery was evicted")
+	}
+	if seen, _ := m.Seen("a"); seen {
+		t.Error("oldest delivery wasn't evicted")
+	}
+}
+
+func TestMemoryDeliveryStoreExpires(t *testing.T) {
+	m := newMemoryDeliveryStore(10, 20*time.Millisecond)
+	m.Seen("a")
+	m.Seen("b")
+	time.Sleep(40 * time.Millisecond)
+
+	if seen, _ := m.Seen("a"); seen {
+		t.Error("expired delivery was seen")
+	}
+	// Recording "a" again drops every expired delivery, not just "a".
+	if _, ok := m.byID["b"]; ok {
+		t.Error("expired delivery was kept")
+	}
+	if seen, _ := m.Seen("a"); !seen {
+		t.Error("delivery wasn't recorded again after expiring")
+	}
+}
+
+func TestNewDeliveryStore(t *testing.T) {
+	tests := []struct {
+		args  []string
+		store bool
+		err   string
+	}{
+		{store: true},
+		{args: []string{"-delivery-cache-size=5", "-delivery-cache-ttl=1m"}, store: true},
+		{args: []string{"-delivery-cache-size=0"}},
+		{args: []string{"-delivery-cache-size=-1"}, err: "delivery-cache-size must be a number"},
+		{args: []string{"-delivery-cache-size=lots"}, err: "delivery-cache-size must be a number"},
+		{args: []string{"-delivery-cache-ttl=0s"}, err: "delivery-cache-ttl must be more than 0"},
+		{args: []string{"-delivery-cache-ttl=-1h"}, err: "delivery-cache-ttl must be more than 0"},
+		{args: []string{"-delivery-cache-size=0", "-delivery-cache-ttl=0s"}, err: "delivery-cache-ttl must be more than 0"},
+		{args: []string{"-delivery-cache-ttl=forever"}, err: "delivery-cache-ttl must be a duration"},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		set := newSettings(fs, serveSettings)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.resolve(); err != nil {
+			t.Fatal(err)
+		}
+
+		store, err := newDeliveryStore(set)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, want one containing %q", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.args, err)
+			continue
+		}
+		if (store != nil) != tt.store {
+			t.Errorf("%q: got store %v, want one: %v", tt.args, store, tt.store)
+		}
+	}
+}
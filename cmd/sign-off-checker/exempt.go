@@ -0,0 +1,202 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// membershipTTL is how long org and team lookups are cached for.
+const membershipTTL = 10 * time.Minute
+
+// exemptReason returns why the commit is exempt from the check under cfg, or
+// "" if it isn't. Membership lookups that fail are logged and treated as
+// not exempting the commit.
+func exemptReason(client *github.Client, commit *github.RepositoryCommit, cfg *repoConfig) string {
+	exempt := cfg.Exempt
+	login := ""
+	if commit.Author != nil {
+		login = commit.Author.GetLogin()
+	}
+
+	if login != "" {
+		for _, l := range exempt.Logins {
+			if strings.EqualFold(login, l) {
+				return fmt.Sprintf("author %s is exempt", login)
+			}
+		}
+		if exempt.Bots && commit.Author.GetType() == "Bot" {
+			return fmt.Sprintf("author %s is a bot", login)
+		}
+	}
+
+	if commit.Commit != nil && commit.Commit.Author != nil {
+		email := strings.ToLower(commit.Commit.Author.GetEmail())
+		for _, pattern := range exempt.Emails {
+			if ok, _ := path.Match(strings.ToLower(pattern), email); ok && email != "" {
+				return fmt.Sprintf("author email %s is exempt", email)
+			}
+		}
+	}
+
+	if login == "" {
+		return ""
+	}
+	for _, org := range exempt.Orgs {
+		member, err := isOrgMember(client, org, login)
+		if err != nil {
+			log.Printf("Error checking if %s is a member of %s: %v", login, org, err)
+			continue
+		}
+		if member {
+			return fmt.Sprintf("author %s is a member of %s", login, org)
+		}
+	}
+	for _, team := range exempt.Teams {
+		member, err := isTeamMember(client, team, login)
+		if err != nil {
+			log.Printf("Error checking if %s is a member of %s: %v", login, team, err)
+			continue
+		}
+		if member {
+			return fmt.Sprintf("author %s is a member of %s", login, team)
+		}
+	}
+	return ""
+}
+
+type cachedMembership struct {
+	member  bool
+	expires time.Time
+}
+
+var membershipCacheMu sync.Mutex
+var membershipCache = map[string]cachedMembership{}
+
+// cachedLookup returns the cached answer for key, calling lookup and caching
+// its answer if there isn't one or it has expired.
+func cachedLookup(key string, lookup func() (bool, error)) (bool, error) {
+	membershipCacheMu.Lock()
+	cached, ok := membershipCache[key]
+	membershipCacheMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.member, nil
+	}
+
+	member, err := lookup()
+	if err != nil {
+		return false, err
+	}
+
+	membershipCacheMu.Lock()
+	defer membershipCacheMu.Unlock()
+	now := time.Now()
+	for k, v := range membershipCache {
+		if now.After(v.expires) {
+			delete(membershipCache, k)
+		}
+	}
+	membershipCache[key] = cachedMembership{member: member, expires: now.Add(membershipTTL)}
+	return member, nil
+}
+
+func isOrgMember(client *github.Client, org, login string) (bool, error) {
+	key := fmt.Sprintf("org:%s:%s", strings.ToLower(org), strings.ToLower(login))
+	return cachedLookup(key, func() (bool, error) {
+		var member bool
+		err := callGitHub("Organizations.IsMember", fmt.Sprintf("Checking membership of %s in %s", login, org), func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			member, resp, err = client.Organizations.IsMember(context.TODO(), org, login)
+			return resp, err
+		})
+		return member, err
+	})
+}
+
+// isTeamMember reports whether login is in team, which is given as
+// "org/team-slug".
+func isTeamMember(client *github.Client, team, login string) (bool, error) {
+	parts := strings.SplitN(team, "/", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("team %q is not of the form org/team", team)
+	}
+	key := fmt.Sprintf("team:%s:%s", strings.ToLower(team), strings.ToLower(login))
+	return cachedLookup(key, func() (bool, error) {
+		id, err := teamID(client, parts[0], parts[1])
+		if err != nil {
+			return false, err
+		}
+		var member bool
+		err = callGitHub("Organizations.IsTeamMember", fmt.Sprintf("Checking membership of %s in %s", login, team), func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			member, resp, err = client.Organizations.IsTeamMember(context.TODO(), id, login)
+			return resp, err
+		})
+		return member, err
+	})
+}
+
+var teamIDsMu sync.Mutex
+var teamIDs = map[string]int{}
+
+// teamID looks up the ID of a team from its slug. Team IDs never change so
+// they are cached forever.
+func teamID(client *github.Client, org, slug string) (int, error) {
+	key := strings.ToLower(org + "/" + slug)
+	teamIDsMu.Lock()
+	id, ok := teamIDs[key]
+	teamIDsMu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		var teams []*github.Team
+		var resp *github.Response
+		err := callGitHub("Organizations.ListTeams", fmt.Sprintf("Listing teams of %s", org), func() (*github.Response, error) {
+			var err error
+			teams, resp, err = client.Organizations.ListTeams(context.TODO(), org, opt)
+			return resp, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, team := range teams {
+			if strings.EqualFold(team.GetSlug(), slug) {
+				teamIDsMu.Lock()
+				teamIDs[key] = team.GetID()
+				teamIDsMu.Unlock()
+				return team.GetID(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("no team %s in %s", slug, org)
+		}
+		opt.Page = resp.NextPage
+	}
+}
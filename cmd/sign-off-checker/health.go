@@ -0,0 +1,89 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/heptio/sign-off-checker/pkg/version"
+)
+
+// readinessInterval is how often we check that we can talk to GitHub.
+// Probes report the result of the last check rather than each calling
+// GitHub themselves.
+const readinessInterval = time.Minute
+
+var readyMu sync.Mutex
+var readyErr = errors.New("GitHub credentials not checked yet")
+
+// watchReadiness checks our GitHub credentials every readinessInterval,
+// forever.
+func watchReadiness() {
+	for {
+		err := checkGitHub()
+		readyMu.Lock()
+		if (err == nil) != (readyErr == nil) {
+			if err != nil {
+				log.Printf("Not ready: %v", err)
+			} else {
+				log.Print("Ready")
+			}
+		}
+		readyErr = err
+		readyMu.Unlock()
+		time.Sleep(readinessInterval)
+	}
+}
+
+// checkGitHub checks that GitHub is reachable and accepts our credentials.
+func checkGitHub() error {
+	if app != nil {
+		return app.Verify()
+	}
+	// Getting the rate limits doesn't count against them.
+	_, _, err := tokenClient.RateLimits(context.TODO())
+	return err
+}
+
+// HandleHealthz reports that the process is alive.
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// HandleReadyz reports whether the last check of our GitHub credentials
+// succeeded.
+func HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	readyMu.Lock()
+	err := readyErr
+	readyMu.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("not ready: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// HandleVersion reports the version the binary was built from.
+func HandleVersion(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, version.VERSION)
+}
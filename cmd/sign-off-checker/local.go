@@ -0,0 +1,166 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// localCommits returns the commits in a revision range of the git repo in
+// dir, such as "origin/master..HEAD", oldest first. The commit objects are
+// read with git cat-file so that messages are checked exactly as committed.
+func localCommits(dir, revRange string) ([]*github.RepositoryCommit, error) {
+	out, err := git(dir, nil, "rev-list", "--reverse", revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+	shas := strings.Fields(string(out))
+	if len(shas) == 0 {
+		return nil, nil
+	}
+
+	out, err = git(dir, strings.NewReader(strings.Join(shas, "\n")+"\n"), "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	commits := make([]*github.RepositoryCommit, 0, len(shas))
+	for range shas {
+		commit, err := readCommitObject(r)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// readCommitObject reads one object in the format written by
+// git cat-file --batch.
+func readCommitObject(r *bufio.Reader) (*github.RepositoryCommit, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading git object: %v", err)
+	}
+	var sha, kind string
+	var size int
+	if _, err := fmt.Sscanf(header, "%s %s %d", &sha, &kind, &size); err != nil {
+		return nil, fmt.Errorf("unexpected git cat-file output %q", header)
+	}
+	if kind != "commit" {
+		return nil, fmt.Errorf("%s is a %s, not a commit", sha, kind)
+	}
+	data := make([]byte, size+1) // The object is followed by a newline.
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading commit %s: %v", sha, err)
+	}
+	return parseCommitObject(sha, data[:size])
+}
+
+// identityLineRE matches the author and committer headers of a commit
+// object, which end with a timestamp and time zone.
+var identityLineRE = regexp.MustCompile(`^(.*) <(.*)> \d+ [-+]\d{4}$`)
+
+// parseCommitObject parses a raw commit object into the form the GitHub API
+// returns it in, so that it can be checked the same way. Only the fields
+// the checks look at are set.
+func parseCommitObject(sha string, data []byte) (*github.RepositoryCommit, error) {
+	commit := &github.Commit{SHA: s(sha)}
+	var parents []github.Commit
+	signed := false
+	headers, message := string(data), ""
+	if i := strings.Index(headers, "\n\n"); i >= 0 {
+		headers, message = headers[:i], headers[i+2:]
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		// Continuation lines of multi-line headers, such as gpgsig, start
+		// with a space.
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && parts[0] == "parent" {
+			parents = append(parents, github.Commit{SHA: s(parts[1])})
+			continue
+		}
+		if parts[0] == "gpgsig" || parts[0] == "gpgsig-sha256" {
+			signed = true
+			continue
+		}
+		if len(parts) != 2 || (parts[0] != "author" && parts[0] != "committer") {
+			continue
+		}
+		m := identityLineRE.FindStringSubmatch(parts[1])
+		if m == nil {
+			return nil, fmt.Errorf("commit %s has a malformed %s: %q", sha, parts[0], parts[1])
+		}
+		identity := &github.CommitAuthor{Name: s(m[1]), Email: s(m[2])}
+		if parts[0] == "author" {
+			commit.Author = identity
+		} else {
+			commit.Committer = identity
+		}
+	}
+	commit.Message = s(strings.TrimRight(message, "\n"))
+	// Signatures can only be verified by GitHub, so only unsigned commits
+	// are known to fail.
+	if !signed {
+		verified := false
+		commit.Verification = &github.SignatureVerification{Verified: &verified, Reason: s("unsigned")}
+	}
+	return &github.RepositoryCommit{SHA: s(sha), Commit: commit, Parents: parents}, nil
+}
+
+// localRepoConfig reads the repo config from the working tree of the git
+// repo in dir, using the defaults if there is none.
+func localRepoConfig(dir string) (*repoConfig, error) {
+	out, err := git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(strings.TrimSpace(string(out)), filepath.FromSlash(repoConfigPath))
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return defaultRepoConfig("", ""), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRepoConfig("", "", data)
+}
+
+// git runs a git command in dir and returns its standard output.
+func git(dir string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	testTree      = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+	testAuthor    = "author Jane Dev <jane@example.com> 1500000000 -0700\n"
+	testCommitter = "committer Joe Maintainer <joe@example.com> 1500000100 +0000\n"
+)
+
+func TestParseCommitObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		parents []string
+		message string
+		signed  bool
+		err     string
+	}{
+		{
+			name:    "root commit",
+			data:    testTree + testAuthor + testCommitter + "\nAdd a thing\n\nSigned-off-by: Jane Dev <jane@example.com>\n",
+			message: "Add a thing\n\nSigned-off-by: Jane Dev <jane@example.com>",
+		},
+		{
+			name: "merge",
+			data: testTree +
+				"parent 0123456789abcdef0123456789abcdef01234567\n" +
+				"parent 89abcdef0123456789abcdef0123456789abcdef\n" +
+				testAuthor + testCommitter + "\nMerge branch 'fix'\n",
+			parents: []string{"0123456789abcdef0123456789abcdef01234567", "89abcdef0123456789abcdef0123456789abcdef"},
+			message: "Merge branch 'fix'",
+		},
+		{
+			name: "gpgsig",
+			data: testTree + testAuthor + testCommitter +
+				"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+				" \n" +
+				" iQEzBAABCAAdFiEE\n" +
+				" author Not Jane <not@example.com> 1500000000 -0700\n" +
+				" -----END PGP SIGNATURE-----\n" +
+				"\nSigned commit\n",
+			message: "Signed commit",
+			signed:  true,
+		},
+		{
+			name: "mergetag",
+			data: testTree +
+				"parent 0123456789abcdef0123456789abcdef01234567\n" +
+				"parent 89abcdef0123456789abcdef0123456789abcdef\n" +
+				testAuthor + testCommitter +
+				"mergetag object 89abcdef0123456789abcdef0123456789abcdef\n" +
+				" type commit\n" +
+				" tag v1.0\n" +
+				" tagger Not Jane <not@example.com> 1500000000 -0700\n" +
+				" \n" +
+				" Release 1.0\n" +
+				"\nMerge tag 'v1.0'\n",
+			parents: []string{"0123456789abcdef0123456789abcdef01234567", "89abcdef0123456789abcdef0123456789abcdef"},
+			message: "Merge tag 'v1.0'",
+		},
+		{
+			name: "empty message",
+			data: testTree + testAuthor + testCommitter + "\n",
+		},
+		{
+			name: "no message",
+			data: testTree + testAuthor + testCommitter,
+		},
+		{
+			name: "malformed author",
+			data: testTree + "author Jane Dev jane@example.com 1500000000 -0700\n" + testCommitter + "\nOops\n",
+			err:  "malformed author",
+		},
+		{
+			name: "malformed committer",
+			data: testTree + testAuthor + "committer Joe Maintainer <joe@example.com>\n" + "\nOops\n",
+			err:  "malformed committer",
+		},
+	}
+	for _, tt := range tests {
+		commit, err := parseCommitObject("fedcba9876543210fedcba9876543210fedcba98", []byte(tt.data))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		parents := []string{}
+		for _, parent := range commit.Parents {
+			parents = append(parents, parent.GetSHA())
+		}
+		if tt.parents == nil {
+			tt.parents = []string{}
+		}
+		if !equalStrings(parents, tt.parents) {
+			t.Errorf("%s: got parents %q, want %q", tt.name, parents, tt.parents)
+		}
+		if got := commit.Commit.GetMessage(); got != tt.message {
+			t.Errorf("%s: got message %q, want %q", tt.name, got, tt.message)
+		}
+		if got := commitAuthor(commit); got != "Jane Dev <jane@example.com>" {
+			t.Errorf("%s: got author %q", tt.name, got)
+		}
+		if got := commit.Commit.Committer.GetEmail(); got != "joe@example.com" {
+			t.Errorf("%s: got committer email %q", tt.name, got)
+		}
+		if signed := commit.Commit.Verification == nil; signed != tt.signed {
+			t.Errorf("%s: signed = %v, want %v", tt.name, signed, tt.signed)
+		}
+	}
+}
+
+func TestReadCommitObject(t *testing.T) {
+	object := testTree + testAuthor + testCommitter + "\nFirst\n"
+	out := "0123456789abcdef0123456789abcdef01234567 commit " + strconv.Itoa(len(object)) + "\n" + object + "\n" +
+		"89abcdef0123456789abcdef0123456789abcdef blob 3\nabc\n"
+	r := bufio.NewReader(strings.NewReader(out))
+
+	commit, err := readCommitObject(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.GetSHA() != "0123456789abcdef0123456789abcdef01234567" || commit.Commit.GetMessage() != "First" {
+		t.Errorf("got commit %s with message %q", commit.GetSHA(), commit.Commit.GetMessage())
+	}
+	if _, err := readCommitObject(r); err == nil || !strings.Contains(err.Error(), "is a blob, not a commit") {
+		t.Errorf("reading a blob returned %v", err)
+	}
+}
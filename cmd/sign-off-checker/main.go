@@ -18,68 +18,272 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
+	"github.com/heptio/sign-off-checker/pkg/policy"
+	"github.com/heptio/sign-off-checker/pkg/version"
 )
 
-var client *github.Client
-var secret []byte
+var secrets *webhookSecrets
+var allowed allowlist
+var deliveries deliveryStore
+var reporter Reporter
+var queue *workQueue
 
-var testRE *regexp.Regexp
+// Settings of the serve command.
+var serveSettings = []*setting{
+	{Name: "shared-secret", Env: "SHARED_SECRET", Secret: true,
+		Usage: "Secret shared with GitHub to sign webhooks. Separate several with commas."},
+	{Name: "webhook-secrets-file", Env: "WEBHOOK_SECRETS_FILE",
+		Usage: "JSON file of webhook secrets per repo and org."},
+	{Name: "allowed-repos", Env: "ALLOWED_REPOS",
+		Usage: "Comma separated owners and owner/repo names to act on. Everything is allowed if empty."},
+	{Name: "listen", Env: "LISTEN_ADDR", Default: ":8080",
+		Usage: "Address to listen on."},
+	{Name: "webhook-path", Env: "WEBHOOK_PATH", Default: "/webhook",
+		Usage: "Path to serve webhooks on."},
+	{Name: "tls-cert", Env: "TLS_CERT_FILE",
+		Usage: "TLS certificate file. Serves plain HTTP if unset."},
+	{Name: "tls-key", Env: "TLS_KEY_FILE",
+		Usage: "TLS private key file."},
+	{Name: "read-timeout", Env: "READ_TIMEOUT", Default: "10s",
+		Usage: "Maximum time to read a request."},
+	{Name: "write-timeout", Env: "WRITE_TIMEOUT", Default: "10s",
+		Usage: "Maximum time to write a response."},
+	{Name: "idle-timeout", Env: "IDLE_TIMEOUT", Default: "2m",
+		Usage: "Maximum time to keep idle connections open."},
+	{Name: "shutdown-timeout", Env: "SHUTDOWN_TIMEOUT", Default: "30s",
+		Usage: "Maximum time to wait for in-flight requests and queued checks on shutdown."},
+	{Name: "max-body-bytes", Env: "MAX_BODY_BYTES", Default: strconv.Itoa(25 << 20),
+		Usage: "Maximum size of a webhook payload."},
+	{Name: "delivery-cache-size", Env: "DELIVERY_CACHE_SIZE", Default: "10000",
+		Usage: "Number of webhook delivery IDs to remember, to ignore redeliveries. 0 disables this."},
+	{Name: "delivery-cache-ttl", Env: "DELIVERY_CACHE_TTL", Default: "24h",
+		Usage: "How long to remember webhook delivery IDs for."},
+	{Name: "reporter", Env: "REPORTER", Default: "status",
+		Usage: "How to report results: status or checks."},
+	{Name: "workers", Env: "WORKERS", Default: "4",
+		Usage: "Number of PRs to check at once."},
+	{Name: "queue-size", Env: "QUEUE_SIZE", Default: "100",
+		Usage: "Number of PRs that can wait to be checked."},
+}
 
-func init() {
-	testRE = regexp.MustCompile(`(?mi)^signed-off-by:`)
+func main() {
+	args := os.Args[1:]
+	command := "serve"
+	// With no command, or only flags, keep the old behavior of serving.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		if err := runServe(args); err != nil {
+			log.Fatal(err)
+		}
+	case "check":
+		os.Exit(runCheck(args))
+	case "validate-config":
+		os.Exit(runValidateConfig(args))
+	case "version":
+		fmt.Println(version.VERSION)
+	case "help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
 }
 
-func loggingMiddleware(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL)
-		handler.ServeHTTP(w, r)
-	})
+// runServe serves webhooks until the process is told to stop.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	set := newSettings(fs, serveSettings, githubSettings, claSettings, notifySettings)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("serve takes no arguments, got %q", fs.Args())
+	}
+	if err := set.resolve(); err != nil {
+		return err
+	}
+
+	opts, err := serverOptionsFrom(set)
+	if err != nil {
+		return err
+	}
+
+	if secrets, err = loadWebhookSecrets(set.String("webhook-secrets-file"), splitList(set.String("shared-secret"))); err != nil {
+		return err
+	}
+	allowed = parseAllowlist(set.String("allowed-repos"))
+	if deliveries, err = newDeliveryStore(set); err != nil {
+		return err
+	}
+
+	if err := setupGitHub(set); err != nil {
+		return err
+	}
+
+	if reporter, err = newReporter(set.String("reporter"), app != nil); err != nil {
+		return err
+	}
+	if claRegistry, err = newCLARegistry(set); err != nil {
+		return err
+	}
+	notifyURL = set.String("notify-url")
+
+	workers, queueSize, err := queueSettings(set)
+	if err != nil {
+		return err
+	}
+	queue = newWorkQueue(queueSize)
+	queue.Start(workers)
+
+	go watchReadiness()
+
+	return runServer(opts)
 }
 
-func main() {
-	secretString, _ := os.LookupEnv("SHARED_SECRET")
-	if secretString == "" {
-		log.Fatal("SHARED_SECRET is not set")
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(value string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
 	}
-	secret = []byte(secretString)
+	return list
+}
 
-	token, _ := os.LookupEnv("GITHUB_TOKEN")
-	if token == "" {
-		log.Fatal("GITHUB_TOKEN is not set")
+// newReporter returns the reporter for mode. Check runs can only be
+// created by a GitHub App, so the checks reporter needs asApp.
+func newReporter(mode string, asApp bool) (Reporter, error) {
+	switch mode {
+	case "status":
+		return statusReporter{}, nil
+	case "checks":
+		if !asApp {
+			return nil, errors.New("reporter \"checks\" needs GITHUB_APP_ID to be set: check runs can only be created by a GitHub App")
+		}
+		return checksReporter{}, nil
 	}
+	return nil, fmt.Errorf("reporter must be \"status\" or \"checks\", not %q", mode)
+}
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(oauth2.NoContext, ts)
-	client = github.NewClient(tc)
+// queueSettings returns the number of workers and the queue size.
+func queueSettings(set *settings) (workers, queueSize int, err error) {
+	workers, err = set.Int("workers")
+	if err != nil || workers < 1 {
+		return 0, 0, errors.New("workers must be a positive number")
+	}
+	queueSize, err = set.Int("queue-size")
+	if err != nil || queueSize < 1 {
+		return 0, 0, errors.New("queue-size must be a positive number")
+	}
+	return workers, queueSize, nil
+}
 
-	log.Print("Starting serving /webhook on :8080")
-	http.Handle("/webhook", loggingMiddleware(http.HandlerFunc(HandleHook)))
-	err := http.ListenAndServe(":8080", nil)
-	log.Fatal(err)
+// serverOptionsFrom builds the server options from resolved settings.
+func serverOptionsFrom(set *settings) (serverOptions, error) {
+	opts := serverOptions{
+		ListenAddr:  set.String("listen"),
+		WebhookPath: set.String("webhook-path"),
+		TLSCertFile: set.String("tls-cert"),
+		TLSKeyFile:  set.String("tls-key"),
+	}
+	for name, d := range map[string]*time.Duration{
+		"read-timeout":     &opts.ReadTimeout,
+		"write-timeout":    &opts.WriteTimeout,
+		"idle-timeout":     &opts.IdleTimeout,
+		"shutdown-timeout": &opts.ShutdownTimeout,
+	} {
+		var err error
+		if *d, err = set.Duration(name); err != nil {
+			return opts, err
+		}
+	}
+	maxBody, err := set.Int("max-body-bytes")
+	if err != nil {
+		return opts, err
+	}
+	opts.MaxBodyBytes = int64(maxBody)
+	return opts, nil
 }
 
 func HandleHook(w http.ResponseWriter, r *http.Request) {
-	payload, err := github.ValidatePayload(r, secret)
+	hooktype := github.WebHookType(r)
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		countDelivery(hooktype, "bad_payload")
+		http.Error(w,
+			fmt.Sprintf("Error reading payload: %v", err),
+			http.StatusBadRequest)
+		return
+	}
+
+	// Which secrets are valid depends on the repo, which we have to take
+	// from the payload before we know it is genuine.
+	owner, repo, err := parseWebhookSource(payload)
 	if err != nil {
+		countDelivery(hooktype, "bad_payload")
+		http.Error(w,
+			fmt.Sprintf("Error parsing payload: %v", err),
+			http.StatusBadRequest)
+		return
+	}
+	if err := checkSignature(r.Header.Get("X-Hub-Signature"), payload, secrets.forRepo(owner, repo)); err != nil {
+		signatureFailures.Inc()
+		countDelivery(hooktype, "invalid_signature")
 		http.Error(w,
 			fmt.Sprintf("Could not validate signature: %v", err),
 			http.StatusBadRequest)
 		return
 	}
+	if !allowed.allows(owner, repo) {
+		countDelivery(hooktype, "not_allowed")
+		log.Printf("Rejecting %s event for %s/%s, which is not in the allowlist", hooktype, owner, repo)
+		http.Error(w, "Repository is not allowed", http.StatusForbidden)
+		return
+	}
+
+	id := github.DeliveryID(r)
+	if deliveries != nil && id != "" {
+		seen, err := deliveries.Seen(id)
+		if err != nil {
+			log.Printf("Error recording delivery %s, handling it anyway: %v", id, err)
+		} else if seen {
+			countDelivery(hooktype, "duplicate")
+			log.Printf("Ignoring %s event for %s/%s: delivery %s was already handled, it was redelivered or replayed", hooktype, owner, repo, id)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, "Duplicate delivery ignored")
+			return
+		}
+	}
+
+	// go-github doesn't know about check_run and check_suite events yet.
+	switch hooktype {
+	case "check_run":
+		respond(w, hooktype, id, HandleCheckRun(payload))
+		return
+	case "check_suite":
+		respond(w, hooktype, id, HandleCheckSuite(payload))
+		return
+	}
 
-	hooktype := github.WebHookType(r)
 	event, err := github.ParseWebHook(hooktype, payload)
 	if err != nil {
+		countDelivery(hooktype, "bad_payload")
 		http.Error(w,
 			fmt.Sprintf("Error parsing payload: %v", err),
 			http.StatusBadRequest)
@@ -87,59 +291,348 @@ func HandleHook(w http.ResponseWriter, r *http.Request) {
 	}
 	switch event := event.(type) {
 	case *github.PullRequestEvent:
-		HandlePullRequest(event)
+		respond(w, hooktype, id, HandlePullRequest(event))
+	case *github.IssueCommentEvent:
+		respond(w, hooktype, id, HandleIssueComment(event))
+	case *github.PushEvent:
+		respond(w, hooktype, id, HandlePush(event))
 	default:
+		countDelivery(hooktype, "unhandled")
 		log.Printf("Unhandled hook type: %v", hooktype)
 	}
 }
 
-func HandlePullRequest(event *github.PullRequestEvent) {
-	owner := event.Repo.Owner.Login
-	repo := event.Repo.Name
-	number := event.Number
+// respond writes the response to a webhook delivery that may have queued
+// work. Work that is queued is reported as accepted rather than done. A
+// delivery that couldn't be queued is forgotten so that it is handled if it
+// is redelivered.
+func respond(w http.ResponseWriter, hooktype, id string, err error) {
+	unavailable := err == errQueueFull || err == errQueueClosed
+	if unavailable && deliveries != nil && id != "" {
+		if err := deliveries.Forget(id); err != nil {
+			log.Printf("Error forgetting delivery %s: %v", id, err)
+		}
+	}
+
+	switch err {
+	case nil:
+		countDelivery(hooktype, "ignored")
+		w.WriteHeader(http.StatusOK)
+	case errQueued:
+		countDelivery(hooktype, "queued")
+		w.WriteHeader(http.StatusAccepted)
+	case errQueueFull, errQueueClosed:
+		countDelivery(hooktype, "unavailable")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		countDelivery(hooktype, "bad_payload")
+		http.Error(w,
+			fmt.Sprintf("Error handling payload: %v", err),
+			http.StatusBadRequest)
+	}
+}
+
+// enqueueCheck queues a check of a PR.
+func enqueueCheck(installation *github.Installation, owner, repo string, number int, baseSHA, headSHA string) error {
+	return enqueuePullRequest(installation, owner, repo, number, func(client *github.Client) {
+		CheckPullRequest(client, owner, repo, number, baseSHA, headSHA)
+	})
+}
+
+// enqueuePullRequest queues a check of a PR. A check that is waiting is
+// replaced by a later one, so that only the latest state of the PR is
+// checked.
+func enqueuePullRequest(installation *github.Installation, owner, repo string, number int, work func(client *github.Client)) error {
+	return enqueue(installation, pullRequestKey(owner, repo, number), true, work)
+}
+
+// enqueueOverride queues an override of a PR. It runs after any work
+// already queued for the PR, and before work queued later, so that a
+// check and an override of the same PR never run at once.
+func enqueueOverride(installation *github.Installation, owner, repo string, number int, work func(client *github.Client)) error {
+	return enqueue(installation, pullRequestKey(owner, repo, number), false, work)
+}
+
+// pullRequestKey is the work queue key of a PR.
+func pullRequestKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// enqueue queues work under key. If replace is set, it replaces work under
+// the same key that was also queued with replace and hasn't started yet.
+func enqueue(installation *github.Installation, key string, replace bool, work func(client *github.Client)) error {
+	add := queue.Append
+	if replace {
+		add = queue.Add
+	}
+	err := add(key, func() {
+		client, err := clientFor(installation)
+		if err != nil {
+			log.Printf("Error getting client for %s: %v", key, err)
+			return
+		}
+		work(client)
+	})
+	if err != nil {
+		log.Printf("Error queueing work on %s: %v", key, err)
+		return err
+	}
+	return errQueued
+}
+
+func HandlePullRequest(event *github.PullRequestEvent) error {
+	if !changesCommits(event) {
+		log.Printf("Ignoring %s action on %s#%d", event.GetAction(), event.Repo.GetFullName(), event.GetNumber())
+		return nil
+	}
+	return enqueueCheck(event.Installation, *event.Repo.Owner.Login, *event.Repo.Name, *event.Number,
+		event.PullRequest.Base.GetSHA(), event.PullRequest.Head.GetSHA())
+}
+
+// changesCommits reports whether the event could change the commits in the
+// PR or the rules they are checked against.
+func changesCommits(event *github.PullRequestEvent) bool {
+	switch event.GetAction() {
+	case "opened", "synchronize", "reopened":
+		return true
+	case "edited":
+		// Changing the base branch changes both the commits and the repo
+		// config. go-github doesn't expose changes to the base, so treat any
+		// edit that isn't to the title or body as one.
+		return event.Changes == nil || (event.Changes.Title == nil && event.Changes.Body == nil)
+	default:
+		return false
+	}
+}
+
+// CheckPullRequest checks every commit in a PR and reports the results.
+// The repo config is read as of baseSHA. Either SHA may be left empty for
+// events that don't include them, in which case they are looked up.
+func CheckPullRequest(client *github.Client, owner, repo string, number int, baseSHA, headSHA string) {
+	if baseSHA == "" || headSHA == "" {
+		pr, err := getPullRequest(client, owner, repo, number)
+		if err != nil {
+			log.Printf("Error getting PR: %v", err)
+			return
+		}
+		baseSHA, headSHA = pr.Base.GetSHA(), pr.Head.GetSHA()
+	}
+
+	cfg := loadRepoConfig(client, owner, repo, baseSHA)
+
+	allCommits, err := listPullRequestCommits(client, owner, repo, number)
+	if err != nil {
+		log.Printf("Error getting commits for PR: %v", err)
+		return
+	}
+
+	ruleResults := checkCommits(client, fmt.Sprintf("%s/%s#%d", owner, repo, number), allCommits, cfg)
+	results := combineResults(ruleResults)
+	countCommits(results)
+	if len(unsignedSHAs(results)) > 0 {
+		prsChecked.Inc("failed")
+	} else {
+		prsChecked.Inc("passed")
+	}
+
+	// The head commit is the one GitHub shows on the PR, so it carries the
+	// result for the PR as a whole.
+	anyOverridden := false
+	for _, rr := range ruleResults {
+		// A maintainer's override lasts until the head changes.
+		if len(unsignedSHAs(rr.Results)) > 0 {
+			overridden, err := reporter.Overridden(client, owner, repo, headSHA, rr.Config)
+			if err != nil {
+				log.Printf("Error checking for an override of %s on %s/%s#%d: %v", rr.Config.Context, owner, repo, number, err)
+			} else if overridden {
+				log.Printf("%s/%s#%d failed %s but was overridden, leaving it", owner, repo, number, rr.Config.Context)
+				anyOverridden = true
+				continue
+			}
+		}
+		if err := reporter.Report(client, owner, repo, headSHA, rr.Results, rr.Config); err != nil {
+			log.Printf("Error reporting %s results for %s/%s#%d: %v", rr.Config.Context, owner, repo, number, err)
+		}
+	}
+
+	if !anyOverridden {
+		UpdateComment(client, owner, repo, number, results, cfg)
+	}
+}
+
+func getPullRequest(client *github.Client, owner, repo string, number int) (*github.PullRequest, error) {
+	var pr *github.PullRequest
+	err := callGitHub("PullRequests.Get", fmt.Sprintf("Getting %s/%s#%d", owner, repo, number), func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		pr, resp, err = client.PullRequests.Get(context.TODO(), owner, repo, number)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pr.Base == nil || pr.Head == nil {
+		return nil, fmt.Errorf("%s/%s#%d has no base or head", owner, repo, number)
+	}
+	return pr, nil
+}
 
+// listPullRequestCommits returns every commit in a PR, oldest first.
+func listPullRequestCommits(client *github.Client, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
 	opt := &github.ListOptions{PerPage: 10}
 	allCommits := []*github.RepositoryCommit{}
 	for {
-		commits, resp, err := client.PullRequests.ListCommits(context.TODO(), *owner, *repo, *number, opt)
+		var commits []*github.RepositoryCommit
+		var resp *github.Response
+		err := callGitHub("PullRequests.ListCommits", fmt.Sprintf("Listing commits of %s/%s#%d", owner, repo, number), func() (*github.Response, error) {
+			var err error
+			commits, resp, err = client.PullRequests.ListCommits(context.TODO(), owner, repo, number, opt)
+			return resp, err
+		})
 		if err != nil {
-			log.Printf("Error getting commits for PR: %v", err)
-			return
+			return nil, err
 		}
 		allCommits = append(allCommits, commits...)
 		if resp.NextPage == 0 {
-			break
+			return allCommits, nil
 		}
 		opt.Page = resp.NextPage
 	}
+}
 
-	signMissing := false
-	for _, commit := range allCommits {
-		if !testRE.MatchString(*commit.Commit.Message) {
-			signMissing = true
-			break
+// ruleResult is the outcome of checking the commits in a PR against one
+// rule.
+type ruleResult struct {
+	// Config is the repo config for reporting the rule.
+	Config  *repoConfig
+	Results []commitResult
+}
+
+// checkCommits checks each commit against every rule in the repo config,
+// logging failures and exemptions under name. Exempt commits are exempt
+// from every rule.
+func checkCommits(client *github.Client, name string, commits []*github.RepositoryCommit, cfg *repoConfig) []ruleResult {
+	exempt := make([]string, len(commits))
+	for i, commit := range commits {
+		if reason := exemptReason(client, commit, cfg); reason != "" {
+			log.Printf("%s: commit %s: %s", name, *commit.SHA, reason)
+			exempt[i] = reason
 		}
 	}
 
-	for _, commit := range allCommits {
-		status := github.RepoStatus{}
-		status.TargetURL = s(fmt.Sprintf("https://github.com/%s/%s/blob/master/CONTRIBUTING.md", *owner, *repo))
-		status.Context = s("signed-off-by")
-		if signMissing {
-			status.State = s("failure")
-			status.Description = s("A commit in PR is missing Signed-off-by")
-		} else {
-			status.State = s("success")
-			status.Description = s("Commit has Signed-off-by")
+	// Exempt commits are checked along with the rest, and their results
+	// ignored, so that rules looking at several commits at once see them.
+	checked := make([]*policy.Commit, len(commits))
+	for i, commit := range commits {
+		checked[i] = policyCommit(commit)
+	}
+
+	ruleResults := make([]ruleResult, 0, len(cfg.rules))
+	for _, rule := range cfg.rules {
+		rr := ruleResult{Config: cfg.forRule(rule)}
+		errs := policy.CheckAll(rule, checked)
+		for i, commit := range commits {
+			if exempt[i] != "" {
+				rr.Results = append(rr.Results, commitResult{Commit: commit, Exempt: exempt[i]})
+				continue
+			}
+			err := errs[i]
+			if err != nil {
+				log.Printf("%s: commit %s: %s: %v", name, *commit.SHA, rule.Name(), err)
+			}
+			rr.Results = append(rr.Results, commitResult{Commit: commit, Err: err})
 		}
+		ruleResults = append(ruleResults, rr)
+	}
+	return ruleResults
+}
 
-		_, _, err := client.Repositories.CreateStatus(context.TODO(), *owner, *repo, *commit.SHA, &status)
-		if err != nil {
-			log.Printf("Error setting status: %v", err)
+// combineResults merges the results of every rule into one result per
+// commit. A commit fails if it fails any of the rules.
+func combineResults(ruleResults []ruleResult) []commitResult {
+	if len(ruleResults) == 1 {
+		return ruleResults[0].Results
+	}
+	combined := []commitResult{}
+	for i, rr := range ruleResults {
+		for j, result := range rr.Results {
+			if i == 0 {
+				combined = append(combined, commitResult{Commit: result.Commit, Exempt: result.Exempt})
+			}
+			if result.Err == nil {
+				continue
+			}
+			problem := rr.Config.Context + ": " + result.Err.Error()
+			if combined[j].Err != nil {
+				problem = combined[j].Err.Error() + "; " + problem
+			}
+			combined[j].Err = errors.New(problem)
+		}
+	}
+	return combined
+}
+
+// policyCommit converts a commit from the GitHub API for checking.
+func policyCommit(commit *github.RepositoryCommit) *policy.Commit {
+	c := &policy.Commit{SHA: commit.GetSHA(), Merge: len(commit.Parents) > 1}
+	if commit.Author != nil {
+		c.AuthorLogin = commit.Author.GetLogin()
+	}
+	if commit.Committer != nil {
+		c.CommitterLogin = commit.Committer.GetLogin()
+	}
+	if commit.Commit != nil {
+		c.Message = commit.Commit.GetMessage()
+		if author := commit.Commit.Author; author != nil {
+			c.Author = policy.Identity{Name: author.GetName(), Email: author.GetEmail()}
+		}
+		if committer := commit.Commit.Committer; committer != nil {
+			c.Committer = policy.Identity{Name: committer.GetName(), Email: committer.GetEmail()}
+		}
+		if v := commit.Commit.Verification; v != nil {
+			c.Verification = &policy.Verification{Verified: v.GetVerified(), Reason: v.GetReason()}
+		}
+	}
+	return c
+}
+
+// countCommits records the results of checking commits in the metrics.
+func countCommits(results []commitResult) {
+	for _, result := range results {
+		switch {
+		case result.Exempt != "":
+			commitsChecked.Inc("exempt")
+		case result.Err != nil:
+			commitsChecked.Inc("failed")
+		default:
+			commitsChecked.Inc("passed")
 		}
 	}
 }
 
+// commitResult is the outcome of checking a single commit in a PR.
+type commitResult struct {
+	Commit *github.RepositoryCommit
+	Err    error
+
+	// Exempt is why the commit wasn't checked, if it wasn't.
+	Exempt string
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func truncate(str string, max int) string {
+	if len(str) <= max {
+		return str
+	}
+	return str[:max-3] + "..."
+}
+
 func s(str string) *string {
 	return &str
 }
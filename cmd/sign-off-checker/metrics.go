@@ -0,0 +1,70 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/heptio/sign-off-checker/pkg/metrics"
+)
+
+var (
+	webhookDeliveries = metrics.NewCounterVec("sign_off_checker_webhook_deliveries_total",
+		"Webhook deliveries received, by event type and outcome.", "event", "outcome")
+	signatureFailures = metrics.NewCounterVec("sign_off_checker_webhook_signature_failures_total",
+		"Webhook deliveries whose signature could not be validated.")
+	prsChecked = metrics.NewCounterVec("sign_off_checker_prs_checked_total",
+		"PRs checked, by result.", "result")
+	pushesChecked = metrics.NewCounterVec("sign_off_checker_pushes_checked_total",
+		"Pushes to branches checked, by result.", "result")
+	commitsChecked = metrics.NewCounterVec("sign_off_checker_commits_checked_total",
+		"Commits checked, by result: passed, failed or exempt.", "result")
+	githubCalls = metrics.NewHistogramVec("sign_off_checker_github_api_call_duration_seconds",
+		"Latency of GitHub API calls, including failed attempts, by endpoint.", metrics.DefBuckets, "endpoint")
+	githubErrors = metrics.NewCounterVec("sign_off_checker_github_api_errors_total",
+		"GitHub API calls that failed, by endpoint and whether they were retried.", "endpoint", "kind")
+	githubRateLimitRemaining = metrics.NewGaugeVec("sign_off_checker_github_rate_limit_remaining",
+		"Requests remaining in the current GitHub rate limit window, as of the last API call.")
+)
+
+// knownEvents are the event types counted under their own name. Others are
+// counted as "other", since the event type header is set by whoever sends
+// the request and each label value is kept forever.
+var knownEvents = map[string]bool{
+	"check_run":     true,
+	"check_suite":   true,
+	"issue_comment": true,
+	"ping":          true,
+	"pull_request":  true,
+	"push":          true,
+}
+
+// countDelivery counts a webhook delivery by event type and outcome.
+func countDelivery(hooktype, outcome string) {
+	if !knownEvents[hooktype] {
+		hooktype = "other"
+	}
+	webhookDeliveries.Inc(hooktype, outcome)
+}
+
+func init() {
+	metrics.NewGaugeFunc("sign_off_checker_work_queue_depth",
+		"PRs waiting to be checked.", func() float64 {
+			if queue == nil {
+				return 0
+			}
+			return float64(queue.Len())
+		})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// overridePrefix starts the description of an overridden status or check
+// run. It is how later checks of the same head commit recognize the
+// override and leave it alone.
+const overridePrefix = "Overridden by @"
+
+// OverridePullRequest marks the head of a PR as passing on behalf of login,
+// if they have write access to the repo. sha is the head commit they
+// checked, in full or abbreviated. Nothing is overridden unless it is still
+// the head, so that commits the maintainer hasn't seen can't pass. The
+// override lasts until the PR is pushed to, since the next check is of a
+// different head commit. The outcome is posted as a reply on the PR.
+func OverridePullRequest(client *github.Client, owner, repo string, number int, sha, login, reason string) {
+	name := fmt.Sprintf("%s/%s#%d", owner, repo, number)
+	allowed, err := canOverride(client, owner, repo, login)
+	if err != nil {
+		log.Printf("Error getting permission of %s on %s/%s: %v", login, owner, repo, err)
+		return
+	}
+	if !allowed {
+		log.Printf("%s tried to override %s without write access", login, name)
+		replyOnPullRequest(client, owner, repo, number, fmt.Sprintf(
+			"@%s only people with write access to this repository can override the sign-off check.", login))
+		return
+	}
+
+	pr, err := getPullRequest(client, owner, repo, number)
+	if err != nil {
+		log.Printf("Error getting PR: %v", err)
+		return
+	}
+	headSHA := pr.Head.GetSHA()
+	if sha == "" || reason == "" {
+		replyOnPullRequest(client, owner, repo, number, fmt.Sprintf(
+			"@%s please give the head commit you checked and a reason, as in `%s %s typo fix from a first time contributor`.",
+			login, overrideCommand, shortSHA(headSHA)))
+		return
+	}
+	if !strings.HasPrefix(headSHA, sha) {
+		log.Printf("Not overriding %s for %s: they asked to override %s, the head is %s", name, login, sha, shortSHA(headSHA))
+		replyOnPullRequest(client, owner, repo, number, fmt.Sprintf(
+			"@%s the head of this PR is %s, not %s, so nothing was overridden. Please check the new commits and comment `%s %s <reason>` if they should pass.",
+			login, shortSHA(headSHA), sha, overrideCommand, shortSHA(headSHA)))
+		return
+	}
+	cfg := loadRepoConfig(client, owner, repo, pr.Base.GetSHA())
+	if claRuleFor(cfg) != nil && len(cfg.rules) == 1 {
+		replyOnPullRequest(client, owner, repo, number, fmt.Sprintf(
+			"@%s the CLA check can't be overridden. The authors need to sign the CLA at %s.", login, cfg.CLA.URL))
+		return
+	}
+	description := fmt.Sprintf("%s%s: %s", overridePrefix, login, reason)
+	for _, rule := range cfg.rules {
+		// Only signing the CLA can satisfy it.
+		if _, ok := rule.(*claRule); ok {
+			continue
+		}
+		if err := reporter.Override(client, owner, repo, headSHA, description, cfg.forRule(rule)); err != nil {
+			log.Printf("Error overriding %s on %s: %v", rule.Name(), name, err)
+			return
+		}
+	}
+	log.Printf("%s overrode %s at %s: %s", login, name, shortSHA(headSHA), reason)
+	replyOnPullRequest(client, owner, repo, number, fmt.Sprintf(
+		"@%s overrode the sign-off check on %s: %s\n\nThe commits will be checked again when the PR is next pushed to.%s",
+		login, shortSHA(headSHA), reason, claOverrideNote(cfg)))
+}
+
+// canOverride reports whether login has write or admin permission on the
+// repo.
+func canOverride(client *github.Client, owner, repo, login string) (bool, error) {
+	var level *github.RepositoryPermissionLevel
+	err := callGitHub("Repositories.GetPermissionLevel", fmt.Sprintf("Getting permission of %s on %s/%s", login, owner, repo), func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		level, resp, err = client.Repositories.GetPermissionLevel(context.TODO(), owner, repo, login)
+		return resp, err
+	})
+	if err != nil {
+		return false, err
+	}
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+	return false, nil
+}
+
+// overrideSHARE matches a full or abbreviated commit SHA.
+var overrideSHARE = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// parseOverride splits the arguments of an override command into the head
+// commit it is for and the reason. sha is empty if the first argument isn't
+// a commit SHA.
+func parseOverride(args string) (sha, reason string) {
+	parts := strings.SplitN(args, " ", 2)
+	if !overrideSHARE.MatchString(parts[0]) {
+		return "", args
+	}
+	sha = strings.ToLower(parts[0])
+	if len(parts) == 2 {
+		reason = strings.TrimSpace(parts[1])
+	}
+	return sha, reason
+}
+
+// replyOnPullRequest posts a new comment on a PR.
+func replyOnPullRequest(client *github.Client, owner, repo string, number int, body string) {
+	// Replies can repeat each other, so each is marked to tell it apart.
+	marker := fmt.Sprintf("<!-- sign-off-checker reply %d -->", time.Now().UnixNano())
+	if err := createComment(client, owner, repo, number, body+"\n"+marker+"\n", marker); err != nil {
+		log.Printf("Error commenting on PR: %v", err)
+	}
+}
+
+// claOverrideNote explains that an override doesn't cover the CLA, if the
+// repo requires one.
+func claOverrideNote(cfg *repoConfig) string {
+	if claRuleFor(cfg) == nil {
+		return ""
+	}
+	return " The CLA check can't be overridden."
+}
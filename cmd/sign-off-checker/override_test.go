@@ -0,0 +1,42 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestParseOverride(t *testing.T) {
+	tests := []struct {
+		args       string
+		wantSHA    string
+		wantReason string
+	}{
+		{args: "abc1234 typo fix", wantSHA: "abc1234", wantReason: "typo fix"},
+		{args: "ABC1234DEF typo fix", wantSHA: "abc1234def", wantReason: "typo fix"},
+		{args: "abc1234  typo fix ", wantSHA: "abc1234", wantReason: "typo fix"},
+		{args: "abc1234", wantSHA: "abc1234"},
+		{args: "typo fix", wantReason: "typo fix"},
+		{args: "abc12 typo fix", wantReason: "abc12 typo fix"},
+		{args: "abc123g typo fix", wantReason: "abc123g typo fix"},
+		{args: ""},
+	}
+	for _, tt := range tests {
+		sha, reason := parseOverride(tt.args)
+		if sha != tt.wantSHA || reason != tt.wantReason {
+			t.Errorf("%q: parseOverride = %q, %q, want %q, %q", tt.args, sha, reason, tt.wantSHA, tt.wantReason)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// zeroSHA is the before or after of a push that creates or deletes a
+// branch.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// mergeQueuePrefix starts the names of the temporary branches GitHub's
+// merge queue pushes to. Their commits need statuses for the queue to
+// merge them.
+const mergeQueuePrefix = "gh-readonly-queue/"
+
+// maxPushEventCommits is the most commits GitHub lists in a push event.
+const maxPushEventCommits = 20
+
+var notifySettings = []*setting{
+	{Name: "notify-url", Env: "NOTIFY_URL", Secret: true,
+		Usage: "Incoming webhook, such as Slack's, to notify when failing commits are pushed to a protected branch."},
+}
+
+// notifyURL is where notifications are posted, if anywhere.
+var notifyURL string
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// pushConfig selects the branches whose pushes are checked and what is done
+// about failing commits pushed to them.
+type pushConfig struct {
+	// Branches are patterns, in the syntax of path.Match, of the branches
+	// checked. They default to the repo's default branch. Merge queue
+	// branches are always checked.
+	Branches []string `json:"branches"`
+
+	// Issue opens an issue when failing commits are pushed to a protected
+	// branch.
+	Issue bool `json:"issue"`
+
+	// Notify posts to the server's notify-url when failing commits are
+	// pushed to a protected branch.
+	Notify bool `json:"notify"`
+}
+
+// checksBranch reports whether pushes to branch are checked.
+func (p *pushConfig) checksBranch(branch, defaultBranch string) bool {
+	if strings.HasPrefix(branch, mergeQueuePrefix) {
+		return true
+	}
+	if len(p.Branches) == 0 {
+		return branch == defaultBranch
+	}
+	for _, pattern := range p.Branches {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HandlePush queues a check of the commits pushed to a branch, which may
+// have bypassed PRs, such as pushes by admins or the merge queue.
+func HandlePush(event *github.PushEvent) error {
+	ref := event.GetRef()
+	parts := strings.SplitN(event.Repo.GetFullName(), "/", 2)
+	if !strings.HasPrefix(ref, "refs/heads/") || event.GetDeleted() || event.GetAfter() == zeroSHA || len(parts) != 2 {
+		log.Printf("Ignoring push to %s of %s", ref, event.Repo.GetFullName())
+		return nil
+	}
+	owner, repo := parts[0], parts[1]
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+
+	// The commits listed in the event are only used for new branches other
+	// than merge queue branches, so only keep those that are new to the
+	// repo.
+	_, queued := mergeQueueBase(branch)
+	if event.GetBefore() == zeroSHA && !queued && len(event.Commits) >= maxPushEventCommits {
+		log.Printf("Push to %s of %s lists %d commits, only checking those", ref, event.Repo.GetFullName(), len(event.Commits))
+	}
+	var shas []string
+	for _, commit := range event.Commits {
+		if commit.GetDistinct() {
+			shas = append(shas, commit.GetID())
+		}
+	}
+
+	before, after := event.GetBefore(), event.GetAfter()
+	defaultBranch := event.Repo.GetDefaultBranch()
+	// Every push is checked, so they are queued by their head rather than
+	// their branch.
+	key := fmt.Sprintf("%s/%s@%s", owner, repo, after)
+	return enqueue(event.Installation, key, true, func(client *github.Client) {
+		CheckPush(client, owner, repo, branch, defaultBranch, before, after, shas)
+	})
+}
+
+// CheckPush checks the commits pushed to a branch, from before to after, and
+// reports the results on after. The repo config is read as of before, so a
+// push can't change its own rules. A new branch has no before: a merge queue
+// branch is compared with the head of the branch it merges into, which the
+// config is read from, and for other branches shas are the commits checked,
+// with the config read from the default branch.
+func CheckPush(client *github.Client, owner, repo, branch, defaultBranch, before, after string, shas []string) {
+	name := fmt.Sprintf("%s/%s@%s", owner, repo, branch)
+	configRef, base := before, before
+	if before == zeroSHA {
+		baseBranch, queued := mergeQueueBase(branch)
+		if !queued {
+			baseBranch = defaultBranch
+		}
+		// The branch is resolved to its head so the config cache, which
+		// assumes refs don't move, can be used.
+		b, err := getBranch(client, owner, repo, baseBranch)
+		if err != nil {
+			log.Printf("Error getting the head of %s/%s@%s for %s: %v", owner, repo, baseBranch, name, err)
+			return
+		}
+		configRef = b.Commit.GetSHA()
+		base = ""
+		if queued {
+			base = configRef
+		}
+	}
+	cfg := loadRepoConfig(client, owner, repo, configRef)
+	if !cfg.Push.checksBranch(branch, defaultBranch) {
+		log.Printf("Ignoring push to %s, the branch isn't checked", name)
+		return
+	}
+
+	var commits []*github.RepositoryCommit
+	var err error
+	if base == "" {
+		commits, err = getCommits(client, owner, repo, shas)
+	} else {
+		commits, err = compareCommits(client, owner, repo, base, after)
+	}
+	if err != nil {
+		log.Printf("Error getting commits pushed to %s: %v", name, err)
+		return
+	}
+	if len(commits) == 0 {
+		log.Printf("No new commits pushed to %s", name)
+		return
+	}
+
+	ruleResults := checkCommits(client, name, commits, cfg)
+	exemptGitHubMerges(ruleResults)
+	results := combineResults(ruleResults)
+	countCommits(results)
+	failed := len(unsignedSHAs(results)) > 0
+	if failed {
+		pushesChecked.Inc("failed")
+	} else {
+		pushesChecked.Inc("passed")
+	}
+
+	for _, rr := range ruleResults {
+		if err := reporter.Report(client, owner, repo, after, rr.Results, rr.Config); err != nil {
+			log.Printf("Error reporting %s results for %s: %v", rr.Config.Context, name, err)
+		}
+	}
+
+	if !failed || (!cfg.Push.Issue && !cfg.Push.Notify) || strings.HasPrefix(branch, mergeQueuePrefix) {
+		return
+	}
+	protected, err := isProtected(client, owner, repo, branch)
+	if err != nil {
+		log.Printf("Error getting protection of %s: %v", name, err)
+		return
+	}
+	if !protected {
+		return
+	}
+	title := fmt.Sprintf("Commits that fail %s were pushed to %s", cfg.Context, branch)
+	if cfg.Push.Issue {
+		reportPushIssue(client, owner, repo, branch, after, title, pushIssueBody(results, cfg, branch, after))
+	}
+	if cfg.Push.Notify {
+		notify(fmt.Sprintf("%s in %s/%s: %s", title, owner, repo, strings.Join(unsignedSHAs(results), ", ")))
+	}
+}
+
+// exemptGitHubMerges exempts the merge commits that GitHub made, such as when
+// merging a PR or adding one to a merge queue, from the results of a push.
+// The commits they merge are checked on their own.
+func exemptGitHubMerges(ruleResults []ruleResult) {
+	for _, rr := range ruleResults {
+		for i := range rr.Results {
+			if githubMerge(rr.Results[i].Commit) && rr.Results[i].Exempt == "" {
+				rr.Results[i] = commitResult{Commit: rr.Results[i].Commit, Exempt: "merge commit made by GitHub"}
+			}
+		}
+	}
+}
+
+// githubMerge reports whether commit is a merge commit that GitHub made,
+// which it commits as web-flow or, where the login isn't known, as
+// noreply@<host>.
+func githubMerge(commit *github.RepositoryCommit) bool {
+	if len(commit.Parents) < 2 {
+		return false
+	}
+	if commit.Committer.GetLogin() == "web-flow" {
+		return true
+	}
+	if commit.Commit == nil || commit.Commit.Committer == nil {
+		return false
+	}
+	u, err := url.Parse(webURL)
+	return err == nil && strings.EqualFold(commit.Commit.Committer.GetEmail(), "noreply@"+u.Hostname())
+}
+
+// mergeQueueBase returns the branch that a merge queue branch, named like
+// gh-readonly-queue/main/pr-123-<sha>, merges into.
+func mergeQueueBase(branch string) (string, bool) {
+	if !strings.HasPrefix(branch, mergeQueuePrefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(branch, mergeQueuePrefix)
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// compareCommits returns the commits in after that aren't in before, oldest
+// first. GitHub lists at most 250 of them.
+func compareCommits(client *github.Client, owner, repo, before, after string) ([]*github.RepositoryCommit, error) {
+	var comparison *github.CommitsComparison
+	err := callGitHub("Repositories.CompareCommits", fmt.Sprintf("Comparing %s/%s %s...%s", owner, repo, shortSHA(before), shortSHA(after)), func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		comparison, resp, err = client.Repositories.CompareCommits(context.TODO(), owner, repo, before, after)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if total := comparison.GetTotalCommits(); total > len(comparison.Commits) {
+		log.Printf("%s/%s %s...%s has %d commits, only checking the first %d", owner, repo, shortSHA(before), shortSHA(after), total, len(comparison.Commits))
+	}
+	commits := make([]*github.RepositoryCommit, len(comparison.Commits))
+	for i := range comparison.Commits {
+		commits[i] = &comparison.Commits[i]
+	}
+	return commits, nil
+}
+
+// getCommits fetches each of the commits.
+func getCommits(client *github.Client, owner, repo string, shas []string) ([]*github.RepositoryCommit, error) {
+	commits := []*github.RepositoryCommit{}
+	for _, sha := range shas {
+		var commit *github.RepositoryCommit
+		err := callGitHub("Repositories.GetCommit", fmt.Sprintf("Getting %s/%s@%s", owner, repo, shortSHA(sha)), func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			commit, resp, err = client.Repositories.GetCommit(context.TODO(), owner, repo, sha)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// getBranch gets a branch, including its head commit.
+func getBranch(client *github.Client, owner, repo, branch string) (*github.Branch, error) {
+	var b *github.Branch
+	err := callGitHub("Repositories.GetBranch", fmt.Sprintf("Getting %s/%s@%s", owner, repo, branch), func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		b, resp, err = client.Repositories.GetBranch(context.TODO(), owner, repo, branch)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// isProtected reports whether a branch is protected.
+func isProtected(client *github.Client, owner, repo, branch string) (bool, error) {
+	b, err := getBranch(client, owner, repo, branch)
+	if err != nil {
+		return false, err
+	}
+	return b.GetProtected(), nil
+}
+
+// pushIssueMarker is hidden in the part of an issue about a failing push of
+// after to branch.
+func pushIssueMarker(branch, after string) string {
+	return fmt.Sprintf("%s%s -->", branchIssueMarker(branch), after)
+}
+
+// branchIssueMarker is the start of pushIssueMarker, which identifies
+// issues about the branch. Git doesn't allow spaces in branch names, so
+// it doesn't match other branches.
+func branchIssueMarker(branch string) string {
+	return fmt.Sprintf("<!-- sign-off-checker push %s ", branch)
+}
+
+// pushIssueBody describes a failing push. It makes up the body of a new
+// issue, or is added to the body of the open issue about the branch.
+func pushIssueBody(results []commitResult, cfg *repoConfig, branch, after string) string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, pushIssueMarker(branch, after))
+	fmt.Fprintf(&b, "The following commits were pushed to `%s`, up to %s, and don't follow this repo's rules for commit messages:\n", branch, after)
+	fmt.Fprintln(&b)
+	failureTable(&b, results)
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "See %s for more details.\n", cfg.HelpURL)
+	return b.String()
+}
+
+// reportPushIssue opens an issue about a failing push to a branch. If we
+// already have an issue about the branch open, the push is added to it
+// instead, unless it is there already, as it is when a push event is
+// redelivered.
+func reportPushIssue(client *github.Client, owner, repo, branch, after, title, body string) {
+	existing, err := findPushIssue(client, owner, repo, branch)
+	if err != nil {
+		log.Printf("Error listing issues: %v", err)
+		return
+	}
+
+	desc := fmt.Sprintf("Reporting a push to %s/%s@%s in an issue", owner, repo, branch)
+	if existing == nil {
+		created := func() (bool, error) {
+			issue, err := findPushIssue(client, owner, repo, branch)
+			return issue != nil, err
+		}
+		err = createOnGitHub("Issues.Create", desc, func() (*github.Response, error) {
+			_, resp, err := client.Issues.Create(context.TODO(), owner, repo, &github.IssueRequest{Title: &title, Body: &body})
+			return resp, err
+		}, created)
+	} else if !strings.Contains(existing.GetBody(), pushIssueMarker(branch, after)) {
+		body = existing.GetBody() + "\n" + body
+		err = callGitHub("Issues.Edit", desc, func() (*github.Response, error) {
+			_, resp, err := client.Issues.Edit(context.TODO(), owner, repo, existing.GetNumber(), &github.IssueRequest{Body: &body})
+			return resp, err
+		})
+	}
+	if err != nil {
+		log.Printf("Error reporting push in an issue: %v", err)
+	}
+}
+
+// findPushIssue returns the open issue we opened about failing pushes to a
+// branch, or nil if there isn't one.
+func findPushIssue(client *github.Client, owner, repo, branch string) (*github.Issue, error) {
+	login, err := botLogin(client)
+	if err != nil {
+		return nil, fmt.Errorf("getting our own login: %v", err)
+	}
+	marker := branchIssueMarker(branch)
+	opt := &github.IssueListByRepoOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var issues []*github.Issue
+		var resp *github.Response
+		err := callGitHub("Issues.ListByRepo", fmt.Sprintf("Listing issues of %s/%s", owner, repo), func() (*github.Response, error) {
+			var err error
+			issues, resp, err = client.Issues.ListByRepo(context.TODO(), owner, repo, opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if issue.PullRequestLinks != nil || issue.User == nil || !strings.EqualFold(issue.User.GetLogin(), login) {
+				continue
+			}
+			if strings.Contains(issue.GetBody(), marker) {
+				return issue, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// notify posts text to notifyURL in the form Slack's incoming webhooks
+// take, which other chat services accept too.
+func notify(text string) {
+	if notifyURL == "" {
+		log.Printf("Not notifying, notify-url isn't set: %s", text)
+		return
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		log.Printf("Error encoding notification: %v", err)
+		return
+	}
+	resp, err := notifyClient.Post(notifyURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error notifying: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("Error notifying: %s", resp.Status)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestMergeQueueBase(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+		queued bool
+	}{
+		{branch: "gh-readonly-queue/main/pr-123-0123456789abcdef0123456789abcdef01234567", want: "main", queued: true},
+		{branch: "gh-readonly-queue/release/1.0/pr-7-0123456789abcdef0123456789abcdef01234567", want: "release/1.0", queued: true},
+		{branch: "gh-readonly-queue/main"},
+		{branch: "main"},
+		{branch: "feature/gh-readonly-queue/main/pr-1"},
+	}
+	for _, tt := range tests {
+		got, queued := mergeQueueBase(tt.branch)
+		if got != tt.want || queued != tt.queued {
+			t.Errorf("mergeQueueBase(%q) = %q, %v, want %q, %v", tt.branch, got, queued, tt.want, tt.queued)
+		}
+	}
+}
+
+func TestChecksBranch(t *testing.T) {
+	tests := []struct {
+		branches []string
+		branch   string
+		want     bool
+	}{
+		{branch: "main", want: true},
+		{branch: "develop"},
+		{branch: "gh-readonly-queue/main/pr-1-abc", want: true},
+		{branches: []string{"release-*"}, branch: "release-1.0", want: true},
+		{branches: []string{"release-*"}, branch: "main"},
+		{branches: []string{"release-*"}, branch: "gh-readonly-queue/release-1.0/pr-1-abc", want: true},
+	}
+	for _, tt := range tests {
+		p := &pushConfig{Branches: tt.branches}
+		if got := p.checksBranch(tt.branch, "main"); got != tt.want {
+			t.Errorf("checksBranch(%q) with branches %q = %v, want %v", tt.branch, tt.branches, got, tt.want)
+		}
+	}
+}
+
+// Merging a PR on GitHub pushes a merge commit without a sign-off to the
+// base branch, which must not fail.
+func TestPushedMergeCommits(t *testing.T) {
+	merge := func(committer *github.CommitAuthor, login string) *github.RepositoryCommit {
+		return &github.RepositoryCommit{
+			SHA: s("fedcba9876543210fedcba9876543210fedcba98"),
+			Commit: &github.Commit{
+				Message:   s("Merge pull request #1 from jane/fix\n\nFix the thing"),
+				Author:    &github.CommitAuthor{Name: s("Jane Dev"), Email: s("jane@example.com")},
+				Committer: committer,
+			},
+			Committer: &github.User{Login: s(login)},
+			Parents:   []github.Commit{{SHA: s("0123456789abcdef0123456789abcdef01234567")}, {SHA: s("89abcdef0123456789abcdef0123456789abcdef")}},
+		}
+	}
+	gh := &github.CommitAuthor{Name: s("GitHub"), Email: s("noreply@github.com")}
+	jane := &github.CommitAuthor{Name: s("Jane Dev"), Email: s("jane@example.com")}
+
+	tests := []struct {
+		name     string
+		commit   *github.RepositoryCommit
+		wantFail bool
+	}{
+		{name: "made by GitHub", commit: merge(gh, "")},
+		{name: "made by web-flow", commit: merge(jane, "web-flow")},
+		{name: "made by someone else", commit: merge(jane, "jdev"), wantFail: true},
+	}
+	cfg := defaultRepoConfig("o", "r")
+	for _, tt := range tests {
+		ruleResults := checkCommits(nil, "o/r@main", []*github.RepositoryCommit{tt.commit}, cfg)
+		exemptGitHubMerges(ruleResults)
+		results := combineResults(ruleResults)
+		if failed := len(unsignedSHAs(results)) > 0; failed != tt.wantFail {
+			t.Errorf("%s: failed = %v, want %v (%v)", tt.name, failed, tt.wantFail, results[0].Err)
+		}
+	}
+
+	// In a PR, merge commits are checked like any other.
+	results := combineResults(checkCommits(nil, "o/r#1", []*github.RepositoryCommit{merge(gh, "")}, cfg))
+	if len(unsignedSHAs(results)) == 0 {
+		t.Errorf("merge commit made by GitHub passed outside a push")
+	}
+}
+
+func TestPushIssueMarker(t *testing.T) {
+	prefix := branchIssueMarker("main")
+	body := pushIssueBody(nil, defaultRepoConfig("o", "r"), "main", "fedcba9876543210fedcba9876543210fedcba98")
+	if !strings.Contains(body, prefix) {
+		t.Errorf("issue about main doesn't contain %q:\n%s", prefix, body)
+	}
+	other := pushIssueBody(nil, defaultRepoConfig("o", "r"), "main-2", "fedcba9876543210fedcba9876543210fedcba98")
+	if strings.Contains(other, prefix) {
+		t.Errorf("issue about main-2 contains %q:\n%s", prefix, other)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errQueued is returned by webhook handlers that queued work rather than
+// doing it.
+var errQueued = errors.New("queued")
+
+var errQueueFull = errors.New("work queue is full")
+var errQueueClosed = errors.New("work queue is shutting down")
+
+// workQueue runs jobs on a fixed pool of workers. Each job has a key, and
+// jobs with the same key run one at a time, in the order they were added.
+// Jobs added with Add replace each other: adding one replaces the last job
+// waiting under the same key if that was also added with Add, so only the
+// latest of them runs. Jobs added with Append are never replaced.
+type workQueue struct {
+	size int
+
+	mu      sync.Mutex
+	pending map[string][]job
+	jobs    int // Across every key in pending.
+	running map[string]bool
+	closed  bool
+
+	// keys holds each pending key once, in the order they were added. It
+	// is closed once the queue is shut down and has no more work.
+	keys       chan string
+	keysClosed bool
+	wg         sync.WaitGroup
+}
+
+type job struct {
+	run         func()
+	replaceable bool
+}
+
+func newWorkQueue(size int) *workQueue {
+	return &workQueue{
+		size:    size,
+		pending: map[string][]job{},
+		running: map[string]bool{},
+		keys:    make(chan string, size),
+	}
+}
+
+// Add queues run under key, replacing the last job waiting under key if it
+// was also added with Add. It fails if the queue already holds its maximum
+// number of jobs or is shutting down.
+func (q *workQueue) Add(key string, run func()) error {
+	return q.add(key, job{run: run, replaceable: true})
+}
+
+// Append queues run under key after every job already waiting under key.
+// It is never replaced by a later job.
+func (q *workQueue) Append(key string, run func()) error {
+	return q.add(key, job{run: run})
+}
+
+func (q *workQueue) add(key string, j job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errQueueClosed
+	}
+	jobs := q.pending[key]
+	if j.replaceable && len(jobs) > 0 && jobs[len(jobs)-1].replaceable {
+		jobs[len(jobs)-1] = j
+		return nil
+	}
+	if q.jobs >= q.size {
+		return errQueueFull
+	}
+	q.pending[key] = append(jobs, j)
+	q.jobs++
+	if len(jobs) == 0 && !q.running[key] {
+		// Otherwise the key is already queued, or the worker running key
+		// queues it again when it is done.
+		q.keys <- key
+	}
+	return nil
+}
+
+// Len returns the number of jobs waiting to run.
+func (q *workQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.jobs
+}
+
+// Start starts the given number of workers.
+func (q *workQueue) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+func (q *workQueue) work() {
+	defer q.wg.Done()
+	for key := range q.keys {
+		q.mu.Lock()
+		jobs := q.pending[key]
+		if len(jobs) == 0 {
+			// The jobs were dropped by a shutdown that timed out.
+			q.mu.Unlock()
+			continue
+		}
+		if len(jobs) == 1 {
+			delete(q.pending, key)
+		} else {
+			q.pending[key] = jobs[1:]
+		}
+		q.jobs--
+		q.running[key] = true
+		q.mu.Unlock()
+
+		jobs[0].run()
+
+		q.mu.Lock()
+		delete(q.running, key)
+		if _, ok := q.pending[key]; ok {
+			q.keys <- key
+		} else {
+			q.closeIfDone()
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Shutdown stops new jobs being added and waits for every queued job to
+// finish, or for ctx to be done. In that case the jobs that haven't started
+// are dropped, jobs that are running are no longer waited for, and the keys
+// of both are returned.
+func (q *workQueue) Shutdown(ctx context.Context) []string {
+	q.mu.Lock()
+	q.closed = true
+	q.closeIfDone()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dropped := []string{}
+	for key := range q.pending {
+		dropped = append(dropped, key)
+	}
+	for key := range q.running {
+		if _, ok := q.pending[key]; !ok {
+			dropped = append(dropped, key)
+		}
+	}
+	q.pending = map[string][]job{}
+	q.jobs = 0
+	q.closeIfDone()
+	sort.Strings(dropped)
+	return dropped
+}
+
+// closeIfDone closes keys once the queue is shut down and there is nothing
+// left to run. q.mu must be held.
+func (q *workQueue) closeIfDone() {
+	if q.closed && !q.keysClosed && len(q.pending) == 0 && len(q.running) == 0 {
+		close(q.keys)
+		q.keysClosed = true
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records which jobs ran.
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) job(name string) func() {
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+	}
+}
+
+func (r *recorder) sorted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ran := append([]string(nil), r.ran...)
+	sort.Strings(ran)
+	return ran
+}
+
+func TestWorkQueueReplacesPendingJobs(t *testing.T) {
+	q := newWorkQueue(10)
+	r := &recorder{}
+	for _, job := range []struct{ key, name string }{
+		{"o/r#1", "first"},
+		{"o/r#2", "other"},
+		{"o/r#1", "second"},
+	} {
+		if err := q.Add(job.key, r.job(job.name)); err != nil {
+			t.Fatalf("Add(%s): %v", job.key, err)
+		}
+	}
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", q.Len())
+	}
+	q.Start(2)
+	q.Shutdown(context.Background())
+
+	if got, want := r.sorted(), []string{"other", "second"}; !equalStrings(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
+
+func TestWorkQueueRunsJobAddedWhileKeyIsRunning(t *testing.T) {
+	q := newWorkQueue(10)
+	r := &recorder{}
+	started, release := make(chan bool), make(chan bool)
+	q.Add("o/r#1", func() {
+		started <- true
+		<-release
+		r.job("first")()
+	})
+	q.Start(2)
+	<-started
+
+	// The first job is running, so this one waits for it rather than
+	// running at the same time on the other worker.
+	q.Add("o/r#1", r.job("second"))
+	close(release)
+	q.Shutdown(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if want := []string{"first", "second"}; !equalStrings(r.ran, want) {
+		t.Errorf("ran %q, want %q", r.ran, want)
+	}
+}
+
+func TestWorkQueueAppendedJobsAreNotReplaced(t *testing.T) {
+	q := newWorkQueue(10)
+	r := &recorder{}
+	q.Add("o/r#1", r.job("check"))
+	q.Append("o/r#1", r.job("override"))
+	// This can't replace the override, so it waits behind it.
+	q.Add("o/r#1", r.job("recheck"))
+	q.Add("o/r#1", r.job("latest recheck"))
+	q.Append("o/r#1", r.job("second override"))
+	if q.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", q.Len())
+	}
+	q.Start(4)
+	q.Shutdown(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if want := []string{"check", "override", "latest recheck", "second override"}; !equalStrings(r.ran, want) {
+		t.Errorf("ran %q, want %q", r.ran, want)
+	}
+}
+
+func TestWorkQueueFull(t *testing.T) {
+	q := newWorkQueue(2)
+	r := &recorder{}
+	q.Add("a", r.job("a"))
+	q.Add("b", r.job("b"))
+	if err := q.Add("c", r.job("c")); err != errQueueFull {
+		t.Errorf("Add to a full queue returned %v, want %v", err, errQueueFull)
+	}
+	if err := q.Append("a", r.job("a3")); err != errQueueFull {
+		t.Errorf("Append to a full queue returned %v, want %v", err, errQueueFull)
+	}
+	// Replacing a pending job doesn't need room.
+	if err := q.Add("a", r.job("a2")); err != nil {
+		t.Errorf("Add replacing a pending job returned %v", err)
+	}
+	q.Start(1)
+	q.Shutdown(context.Background())
+	if got, want := r.sorted(), []string{"a2", "b"}; !equalStrings(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
+
+func TestWorkQueueShutdownDrains(t *testing.T) {
+	q := newWorkQueue(100)
+	r := &recorder{}
+	want := []string{}
+	for i := 0; i < 50; i++ {
+		name := string(rune('A' + i))
+		q.Add(name, r.job(name))
+		want = append(want, name)
+	}
+	q.Start(4)
+	q.Shutdown(context.Background())
+
+	sort.Strings(want)
+	if got := r.sorted(); !equalStrings(got, want) {
+		t.Errorf("ran %d jobs, want all %d", len(got), len(want))
+	}
+	if err := q.Add("late", r.job("late")); err != errQueueClosed {
+		t.Errorf("Add after Shutdown returned %v, want %v", err, errQueueClosed)
+	}
+}
+
+func TestWorkQueueShutdownWhenEmpty(t *testing.T) {
+	q := newWorkQueue(1)
+	q.Start(2)
+	q.Shutdown(context.Background())
+}
+
+func TestWorkQueueShutdownTimesOut(t *testing.T) {
+	q := newWorkQueue(10)
+	r := &recorder{}
+	started, release := make(chan bool), make(chan bool)
+	defer close(release)
+	q.Add("o/r#1", func() {
+		started <- true
+		<-release
+	})
+	q.Add("o/r#2", r.job("waiting"))
+	q.Start(1)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if got, want := q.Shutdown(ctx), []string{"o/r#1", "o/r#2"}; !equalStrings(got, want) {
+		t.Errorf("Shutdown dropped %q, want %q", got, want)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after Shutdown timed out, want 0", q.Len())
+	}
+	if got := r.sorted(); len(got) != 0 {
+		t.Errorf("ran %q after Shutdown timed out, want nothing", got)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
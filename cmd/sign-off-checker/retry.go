@@ -0,0 +1,170 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// maxAttempts is how many times a failing call is tried.
+	maxAttempts = 5
+
+	// baseBackoff is the wait before the first retry. It doubles with each
+	// attempt, up to maxBackoff.
+	baseBackoff = time.Second
+	maxBackoff  = 30 * time.Second
+
+	// maxRateLimitWait is the longest we wait for a rate limit to reset
+	// before giving up.
+	maxRateLimitWait = 15 * time.Minute
+
+	// defaultAbuseWait is how long we back off after hitting the abuse
+	// rate limit when GitHub doesn't say.
+	defaultAbuseWait = time.Minute
+)
+
+// callGitHub runs fn, which makes a single GitHub API call, retrying it
+// while it fails with a rate limit or a transient error. endpoint names the
+// API method for metrics, and desc describes the call, including the repo
+// or PR it is about, for the logs. The call must be safe to repeat.
+func callGitHub(endpoint, desc string, fn func() (*github.Response, error)) error {
+	return retryGitHub(endpoint, desc, fn, nil)
+}
+
+// createOnGitHub is callGitHub for calls that create something, such as a
+// comment, and would create another if repeated. A call that fails with a
+// server or network error may have been acted on anyway, so before it is
+// retried, created reports whether the thing exists after all. Calls that
+// hit a rate limit weren't acted on and are retried as usual.
+func createOnGitHub(endpoint, desc string, fn func() (*github.Response, error), created func() (bool, error)) error {
+	return retryGitHub(endpoint, desc, fn, created)
+}
+
+func retryGitHub(endpoint, desc string, fn func() (*github.Response, error), created func() (bool, error)) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var resp *github.Response
+		start := time.Now()
+		resp, err = fn()
+		githubCalls.Observe(time.Since(start).Seconds(), endpoint)
+		if resp != nil && resp.Rate.Limit > 0 {
+			githubRateLimitRemaining.Set(float64(resp.Rate.Remaining))
+		}
+		if err == nil {
+			return nil
+		}
+
+		wait, retry := retryDelay(err, attempt)
+		if !retry {
+			break
+		}
+		if attempt == maxAttempts-1 {
+			break
+		}
+		githubErrors.Inc(endpoint, "retried")
+		log.Printf("%s failed, retrying in %v: %v", desc, wait.Round(time.Second), err)
+		time.Sleep(wait)
+
+		if created != nil && !isRateLimit(err) {
+			ok, checkErr := created()
+			if checkErr != nil {
+				log.Printf("%s: error checking whether the failed call took effect, not retrying: %v", desc, checkErr)
+				break
+			}
+			if ok {
+				log.Printf("%s took effect despite failing, not retrying", desc)
+				return nil
+			}
+		}
+	}
+	if e, ok := err.(*github.ErrorResponse); !ok || e.Response == nil || e.Response.StatusCode != http.StatusNotFound {
+		// Callers often expect things to not be found, so don't
+		// complain about it here.
+		githubErrors.Inc(endpoint, "permanent")
+		log.Printf("%s failed permanently: %v", desc, err)
+	}
+	return err
+}
+
+// isRateLimit reports whether err says a call was refused because of a rate
+// limit, in which case it wasn't acted on.
+func isRateLimit(err error) bool {
+	switch err.(type) {
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before retrying a call that failed
+// with err, and whether it is worth retrying at all.
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	switch err := err.(type) {
+	case *github.RateLimitError:
+		wait := time.Until(err.Rate.Reset.Time) + time.Second
+		if wait > maxRateLimitWait {
+			return 0, false
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	case *github.AbuseRateLimitError:
+		if err.RetryAfter != nil {
+			return *err.RetryAfter, true
+		}
+		return defaultAbuseWait, true
+	case *github.ErrorResponse:
+		if err.Response != nil && err.Response.StatusCode >= 500 {
+			return backoff(attempt), true
+		}
+		return 0, false
+	}
+	if isNetworkError(err) {
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff returns an exponentially growing wait with jitter, so that
+// workers that failed together don't retry together.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << uint(attempt)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+func isNetworkError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// badGateway is the error of a call that GitHub may have acted on anyway.
+var badGateway = &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+
+func TestCreateOnGitHubDoesNotRepeatCreates(t *testing.T) {
+	calls := 0
+	err := createOnGitHub("Test.Create", "Creating a thing", func() (*github.Response, error) {
+		calls++
+		return nil, badGateway
+	}, func() (bool, error) { return true, nil })
+	if err != nil {
+		t.Errorf("got error %v, want none since the thing was created", err)
+	}
+	if calls != 1 {
+		t.Errorf("made %d calls, want 1", calls)
+	}
+}
+
+func TestCreateOnGitHubRetriesWhenNotCreated(t *testing.T) {
+	calls, checks := 0, 0
+	err := createOnGitHub("Test.Create", "Creating a thing", func() (*github.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, badGateway
+		}
+		return nil, nil
+	}, func() (bool, error) {
+		checks++
+		return false, nil
+	})
+	if err != nil || calls != 2 || checks != 1 {
+		t.Errorf("got error %v after %d calls and %d checks, want none after 2 calls and 1 check", err, calls, checks)
+	}
+}
+
+func TestCreateOnGitHubGivesUpIfCheckFails(t *testing.T) {
+	calls := 0
+	err := createOnGitHub("Test.Create", "Creating a thing", func() (*github.Response, error) {
+		calls++
+		return nil, badGateway
+	}, func() (bool, error) { return false, errors.New("GitHub is down") })
+	if err != badGateway || calls != 1 {
+		t.Errorf("got error %v after %d calls, want the call's error after 1", err, calls)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"strings"
+)
+
+// webhookSecrets holds the secrets webhooks may be signed with. A repo uses
+// its own secrets if it has any, then those of its owner, then the default
+// ones. Listing several secrets for one repo or org allows rotating them:
+// add the new secret, change it on GitHub, then remove the old one.
+type webhookSecrets struct {
+	Default []string            `json:"default"`
+	Orgs    map[string][]string `json:"orgs"`
+	Repos   map[string][]string `json:"repos"`
+}
+
+// loadWebhookSecrets reads the secrets file, if there is one. shared are
+// default secrets from SHARED_SECRET, which take precedence over any in
+// the file.
+func loadWebhookSecrets(file string, shared []string) (*webhookSecrets, error) {
+	secrets := &webhookSecrets{}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, secrets); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", file, err)
+		}
+	}
+	if len(shared) > 0 {
+		secrets.Default = shared
+	}
+
+	// Names on GitHub are case insensitive.
+	orgs, repos := map[string][]string{}, map[string][]string{}
+	for org, list := range secrets.Orgs {
+		orgs[strings.ToLower(org)] = list
+	}
+	for repo, list := range secrets.Repos {
+		if !strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("repo %q in %s is not of the form owner/repo", repo, file)
+		}
+		repos[strings.ToLower(repo)] = list
+	}
+	secrets.Orgs, secrets.Repos = orgs, repos
+
+	if len(secrets.Default) == 0 && len(secrets.Orgs) == 0 && len(secrets.Repos) == 0 {
+		return nil, errors.New("SHARED_SECRET is not set")
+	}
+	return secrets, nil
+}
+
+// forRepo returns the secrets a webhook about the repo may be signed with.
+// repo may be empty for events about an org as a whole.
+func (s *webhookSecrets) forRepo(owner, repo string) []string {
+	if list, ok := s.Repos[strings.ToLower(owner+"/"+repo)]; ok && repo != "" {
+		return list
+	}
+	if list, ok := s.Orgs[strings.ToLower(owner)]; ok {
+		return list
+	}
+	return s.Default
+}
+
+// allowlist limits the repos the server acts on. Entries are either an
+// owner, allowing all of its repos, or "owner/repo". An empty allowlist
+// allows everything.
+type allowlist map[string]bool
+
+func parseAllowlist(value string) allowlist {
+	a := allowlist{}
+	for _, entry := range splitList(value) {
+		a[strings.ToLower(entry)] = true
+	}
+	return a
+}
+
+func (a allowlist) allows(owner, repo string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	owner = strings.ToLower(owner)
+	return a[owner] || (repo != "" && a[owner+"/"+strings.ToLower(repo)])
+}
+
+// webhookSource is the repo and owner a webhook claims to be about. It is
+// read before the signature is checked, in order to know which secrets to
+// check it against, so it must not be trusted until then.
+type webhookSource struct {
+	Repository *struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Organization *struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
+
+// parseWebhookSource returns the owner and repo a payload claims to be
+// about. repo is empty for events about an org, and both are empty if the
+// payload names neither.
+func parseWebhookSource(payload []byte) (owner, repo string, err error) {
+	source := webhookSource{}
+	if err := json.Unmarshal(payload, &source); err != nil {
+		return "", "", err
+	}
+	switch {
+	case source.Repository != nil:
+		return source.Repository.Owner.Login, source.Repository.Name, nil
+	case source.Organization != nil:
+		return source.Organization.Login, "", nil
+	}
+	return "", "", nil
+}
+
+// checkSignature checks the X-Hub-Signature of a payload against each of
+// the secrets.
+func checkSignature(signature string, payload []byte, secrets []string) error {
+	if signature == "" {
+		return errors.New("missing signature")
+	}
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("error parsing signature %q", signature)
+	}
+	var hashFunc func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		hashFunc = sha1.New
+	case "sha256":
+		hashFunc = sha256.New
+	default:
+		return fmt.Errorf("unknown hash type prefix: %q", parts[0])
+	}
+	messageMAC, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("error decoding signature %q: %v", signature, err)
+	}
+
+	for _, secret := range secrets {
+		mac := hmac.New(hashFunc, []byte(secret))
+		mac.Write(payload)
+		if hmac.Equal(messageMAC, mac.Sum(nil)) {
+			return nil
+		}
+	}
+	return errors.New("payload signature check failed")
+}
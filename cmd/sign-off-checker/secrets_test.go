@@ -0,0 +1,188 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"strings"
+	"testing"
+)
+
+func sign(hashFunc func() hash.Hash, prefix, secret string, payload []byte) string {
+	mac := hmac.New(hashFunc, []byte(secret))
+	mac.Write(payload)
+	return prefix + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCheckSignature(t *testing.T) {
+	payload := []byte(`{"repository": {"name": "r", "owner": {"login": "o"}}}`)
+	secrets := []string{"old", "new"}
+	tests := []struct {
+		name      string
+		signature string
+		err       string
+	}{
+		{name: "sha1", signature: sign(sha1.New, "sha1", "old", payload)},
+		{name: "sha256", signature: sign(sha256.New, "sha256", "old", payload)},
+		{name: "rotated secret", signature: sign(sha256.New, "sha256", "new", payload)},
+		{name: "wrong secret", signature: sign(sha256.New, "sha256", "other", payload), err: "signature check failed"},
+		{name: "wrong hash for prefix", signature: sign(sha1.New, "sha256", "old", payload), err: "signature check failed"},
+		{name: "other payload", signature: sign(sha256.New, "sha256", "old", []byte("{}")), err: "signature check failed"},
+		{name: "missing", signature: "", err: "missing signature"},
+		{name: "no prefix", signature: hex.EncodeToString([]byte("abc")), err: "error parsing signature"},
+		{name: "unknown prefix", signature: "md5=" + hex.EncodeToString([]byte("abc")), err: "unknown hash type prefix"},
+		{name: "not hex", signature: "sha256=not-hex", err: "error decoding signature"},
+		{name: "empty", signature: "sha256=", err: "signature check failed"},
+	}
+	for _, tt := range tests {
+		err := checkSignature(tt.signature, payload, secrets)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+
+	if err := checkSignature(sign(sha256.New, "sha256", "old", payload), payload, nil); err == nil {
+		t.Error("signature checked out with no secrets")
+	}
+}
+
+func TestSecretsForRepo(t *testing.T) {
+	path := writeTempFile(t, `{
+		"default": ["default"],
+		"orgs": {"Heptio": ["org"]},
+		"repos": {"heptio/Sign-Off-Checker": ["repo", "repo-next"], "vmware/ark": ["ark"]}
+	}`)
+	defer os.Remove(path)
+	secrets, err := loadWebhookSecrets(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		owner, repo string
+		want        []string
+	}{
+		{"heptio", "sign-off-checker", []string{"repo", "repo-next"}},
+		{"HEPTIO", "sign-off-CHECKER", []string{"repo", "repo-next"}},
+		{"heptio", "contour", []string{"org"}},
+		{"Heptio", "", []string{"org"}},
+		{"vmware", "ark", []string{"ark"}},
+		{"vmware", "", []string{"default"}},
+		{"vmware", "other", []string{"default"}},
+		{"", "", []string{"default"}},
+	}
+	for _, tt := range tests {
+		if got := secrets.forRepo(tt.owner, tt.repo); !equalStrings(got, tt.want) {
+			t.Errorf("forRepo(%q, %q) = %q, want %q", tt.owner, tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWebhookSecrets(t *testing.T) {
+	path := writeTempFile(t, `{"default": ["from-file"], "orgs": {"heptio": ["org"]}}`)
+	defer os.Remove(path)
+	secrets, err := loadWebhookSecrets(path, []string{"shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := secrets.forRepo("vmware", "ark"); !equalStrings(got, []string{"shared"}) {
+		t.Errorf("SHARED_SECRET didn't replace the default secrets, got %q", got)
+	}
+
+	// Without default secrets, only repos with their own secrets can be
+	// served.
+	path = writeTempFile(t, `{"orgs": {"heptio": ["org"]}}`)
+	defer os.Remove(path)
+	if secrets, err = loadWebhookSecrets(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := secrets.forRepo("vmware", "ark"); len(got) != 0 {
+		t.Errorf("got secrets %q for an org without any", got)
+	}
+
+	path = writeTempFile(t, `{"repos": {"sign-off-checker": ["repo"]}}`)
+	defer os.Remove(path)
+	if _, err := loadWebhookSecrets(path, nil); err == nil || !strings.Contains(err.Error(), "not of the form owner/repo") {
+		t.Errorf("got error %v for a repo without an owner", err)
+	}
+	if _, err := loadWebhookSecrets("", nil); err == nil {
+		t.Error("loaded no secrets at all without an error")
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	tests := []struct {
+		list        string
+		owner, repo string
+		want        bool
+	}{
+		{list: "", owner: "anyone", repo: "anything", want: true},
+		{list: "", owner: "anyone", want: true},
+		{list: "heptio", owner: "heptio", repo: "sign-off-checker", want: true},
+		{list: "heptio", owner: "Heptio", repo: "contour", want: true},
+		{list: "heptio", owner: "heptio", want: true},
+		{list: "heptio", owner: "vmware", repo: "ark"},
+		{list: "heptio", owner: "heptio-evil", repo: "sign-off-checker"},
+		{list: "vmware/ark", owner: "vmware", repo: "ark", want: true},
+		{list: "vmware/ark", owner: "VMware", repo: "ARK", want: true},
+		{list: "vmware/ark", owner: "vmware", repo: "other"},
+		{list: "vmware/ark", owner: "vmware"},
+		{list: " heptio , vmware/ark,", owner: "vmware", repo: "ark", want: true},
+		{list: " heptio , vmware/ark,", owner: "heptio", repo: "contour", want: true},
+		{list: "heptio", owner: "", repo: ""},
+	}
+	for _, tt := range tests {
+		if got := parseAllowlist(tt.list).allows(tt.owner, tt.repo); got != tt.want {
+			t.Errorf("allowlist %q allows(%q, %q) = %v, want %v", tt.list, tt.owner, tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestParseWebhookSource(t *testing.T) {
+	tests := []struct {
+		payload     string
+		owner, repo string
+		err         bool
+	}{
+		{payload: `{"repository": {"name": "r", "owner": {"login": "o"}}, "organization": {"login": "org"}}`, owner: "o", repo: "r"},
+		{payload: `{"organization": {"login": "org"}}`, owner: "org"},
+		{payload: `{"zen": "Keep it logically awesome."}`},
+		{payload: `{"repository": "o/r"}`, err: true},
+		{payload: `not json`, err: true},
+	}
+	for _, tt := range tests {
+		owner, repo, err := parseWebhookSource([]byte(tt.payload))
+		if (err != nil) != tt.err {
+			t.Errorf("parseWebhookSource(%s) returned error %v", tt.payload, err)
+			continue
+		}
+		if owner != tt.owner || repo != tt.repo {
+			t.Errorf("parseWebhookSource(%s) = %q, %q, want %q, %q", tt.payload, owner, repo, tt.owner, tt.repo)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/heptio/sign-off-checker/pkg/metrics"
+	"github.com/heptio/sign-off-checker/pkg/version"
+)
+
+// serverOptions controls how the webhook server listens.
+type serverOptions struct {
+	ListenAddr  string
+	WebhookPath string
+
+	// TLS is only used if both files are set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	// MaxBodyBytes limits the size of webhook payloads.
+	MaxBodyBytes int64
+}
+
+// runServer serves webhooks until the process is sent SIGTERM or SIGINT. It
+// then stops accepting requests, waits for in-flight requests and queued
+// checks to finish, for up to opts.ShutdownTimeout in all, and returns.
+func runServer(opts serverOptions) error {
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return errors.New("both or neither of the TLS certificate and key must be set")
+	}
+	if !strings.HasPrefix(opts.WebhookPath, "/") {
+		return fmt.Errorf("webhook path %q must start with /", opts.WebhookPath)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(opts.WebhookPath, loggingMiddleware(maxBodyMiddleware(opts.MaxBodyBytes, http.HandlerFunc(HandleHook))))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", HandleHealthz)
+	mux.HandleFunc("/readyz", HandleReadyz)
+	mux.HandleFunc("/version", HandleVersion)
+
+	server := &http.Server{
+		Addr:         opts.ListenAddr,
+		Handler:      mux,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+	}
+	useTLS := opts.TLSCertFile != ""
+	if useTLS {
+		certs, err := newCertReloader(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+		<-sigs
+		log.Print("Shutting down, finishing in-flight requests and queued work")
+		ctx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+		if dropped := queue.Shutdown(ctx); len(dropped) > 0 {
+			log.Printf("Timed out waiting for queued work, dropping %d jobs: %s", len(dropped), strings.Join(dropped, ", "))
+		}
+		close(done)
+	}()
+
+	log.Printf("Starting serving %s on %s, version %s", opts.WebhookPath, opts.ListenAddr, version.VERSION)
+	var err error
+	if useTLS {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	<-done
+	return nil
+}
+
+func loggingMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// maxBodyMiddleware fails requests with bodies larger than max bytes.
+func maxBodyMiddleware(max int64, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// certReloader serves a TLS certificate from files, loading it again
+// whenever either file changes so that certificates can be rotated without
+// a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		if r.cert != nil {
+			log.Printf("Error checking TLS certificate, using the one already loaded: %v", err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// The files may be part way through being replaced.
+			log.Printf("Error reloading TLS certificate, using the one already loaded: %v", err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	return r.cert, nil
+}
+
+func (r *certReloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// setting is an option that can be given as a flag, an environment variable
+// or a key in the server config file, in that order of precedence.
+type setting struct {
+	// Name is both the flag name and the config file key.
+	Name    string
+	Env     string
+	Default string
+	Usage   string
+
+	// Secret settings have no flag, so that they don't show up in the
+	// process list.
+	Secret bool
+
+	flagValue *string
+	value     string
+}
+
+// Settings shared by every subcommand that talks to GitHub.
+var githubSettings = []*setting{
+	{Name: "github-token", Env: "GITHUB_TOKEN", Secret: true,
+		Usage: "Personal access token to use if not running as a GitHub App."},
+	{Name: "github-app-id", Env: "GITHUB_APP_ID",
+		Usage: "ID of the GitHub App to run as."},
+	{Name: "github-app-private-key", Env: "GITHUB_APP_PRIVATE_KEY",
+		Usage: "Path to the private key of the GitHub App."},
+	{Name: "github-api-url", Env: "GITHUB_API_URL", Default: "https://api.github.com/",
+		Usage: "GitHub API to use, such as https://github.example.com/api/v3/ for GitHub Enterprise."},
+	{Name: "github-upload-url", Env: "GITHUB_UPLOAD_URL", Default: "https://uploads.github.com/",
+		Usage: "GitHub upload API to use, such as https://github.example.com/api/uploads/ for GitHub Enterprise."},
+	{Name: "github-web-url", Env: "GITHUB_WEB_URL", Default: "https://github.com/",
+		Usage: "GitHub web UI to link to, such as https://github.example.com/ for GitHub Enterprise."},
+}
+
+// settings resolves a set of settings registered on a flag set.
+type settings struct {
+	fs         *flag.FlagSet
+	list       []*setting
+	byName     map[string]*setting
+	configFile *string
+}
+
+// newSettings registers a flag for each non-secret setting, as well as a
+// -config flag naming the server config file.
+func newSettings(fs *flag.FlagSet, lists ...[]*setting) *settings {
+	s := &settings{fs: fs, byName: map[string]*setting{}}
+	s.configFile = fs.String("config", os.Getenv("SIGN_OFF_CHECKER_CONFIG"),
+		"Server config file, a JSON object keyed by flag name. Env: SIGN_OFF_CHECKER_CONFIG")
+	for _, list := range lists {
+		for _, set := range list {
+			s.list = append(s.list, set)
+			s.byName[set.Name] = set
+			if set.Secret {
+				continue
+			}
+			usage := set.Usage
+			if set.Env != "" {
+				usage += " Env: " + set.Env
+			}
+			set.flagValue = fs.String(set.Name, set.Default, usage)
+		}
+	}
+	return s
+}
+
+// resolve works out the value of every setting. It must be called after the
+// flag set has been parsed.
+func (s *settings) resolve() error {
+	fromFile, err := loadServerConfig(*s.configFile)
+	if err != nil {
+		return err
+	}
+	for name := range fromFile {
+		if !knownSetting(name) {
+			return fmt.Errorf("%s: unknown setting %q", *s.configFile, name)
+		}
+	}
+
+	setFlags := map[string]bool{}
+	s.fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	for _, set := range s.list {
+		switch {
+		case setFlags[set.Name]:
+			set.value = *set.flagValue
+		case set.Env != "" && os.Getenv(set.Env) != "":
+			set.value = os.Getenv(set.Env)
+		case fromFile[set.Name] != "":
+			set.value = fromFile[set.Name]
+		default:
+			set.value = set.Default
+		}
+	}
+	return nil
+}
+
+// knownSetting reports whether any subcommand has a setting called name.
+// Every subcommand reads the same server config file, so each one ignores
+// the settings of the others.
+func knownSetting(name string) bool {
+	for _, list := range [][]*setting{serveSettings, githubSettings, claSettings, notifySettings} {
+		for _, set := range list {
+			if set.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadServerConfig reads the server config file, if there is one, as a map
+// from setting name to value.
+func loadServerConfig(path string) (map[string]string, error) {
+	values := map[string]string{}
+	if path == "" {
+		return values, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for name, value := range raw {
+		switch value := value.(type) {
+		case string:
+			values[name] = value
+		case json.Number:
+			values[name] = value.String()
+		case bool:
+			values[name] = strconv.FormatBool(value)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, name)
+		}
+	}
+	return values, nil
+}
+
+func (s *settings) String(name string) string {
+	set, ok := s.byName[name]
+	if !ok {
+		panic("unknown setting " + name)
+	}
+	return set.value
+}
+
+func (s *settings) Int(name string) (int, error) {
+	value := s.String(name)
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number, not %q", name, value)
+	}
+	return i, nil
+}
+
+func (s *settings) Duration(name string) (time.Duration, error) {
+	value := s.String(name)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 30s, not %q", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeTempFile writes data to a temporary file and returns its path.
+func writeTempFile(t *testing.T, data string) string {
+	f, err := ioutil.TempFile("", "sign-off-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+// The check command shares the server config file with serve, so it must
+// accept serve's settings.
+func TestResolveIgnoresOtherCommandsSettings(t *testing.T) {
+	path := writeTempFile(t, `{"listen": ":9090", "github-web-url": "https://github.example.com/"}`)
+	defer os.Remove(path)
+
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	set := newSettings(fs, githubSettings, claSettings)
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.resolve(); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if got := set.String("github-web-url"); got != "https://github.example.com/" {
+		t.Errorf("github-web-url = %q, want the value from the file", got)
+	}
+}
+
+func TestResolveRejectsUnknownSettings(t *testing.T) {
+	path := writeTempFile(t, `{"listen": ":9090", "lisen": ":9091"}`)
+	defer os.Remove(path)
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	set := newSettings(fs, serveSettings)
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.resolve(); err == nil || !strings.Contains(err.Error(), `unknown setting "lisen"`) {
+		t.Errorf("resolve returned %v, want an unknown setting error", err)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// maxDescriptionLen is the longest description GitHub accepts on a status.
+const maxDescriptionLen = 140
+
+// Reporter publishes the results of checking the commits in a PR to GitHub.
+type Reporter interface {
+	Report(client *github.Client, owner, repo, headSHA string, results []commitResult, cfg *repoConfig) error
+
+	// Override marks the head commit as passing, whatever the results.
+	// description says who overrode it and why.
+	Override(client *github.Client, owner, repo, headSHA, description string, cfg *repoConfig) error
+
+	// Overridden reports whether the head commit was marked as passing by
+	// Override.
+	Overridden(client *github.Client, owner, repo, headSHA string, cfg *repoConfig) (bool, error)
+}
+
+// statusReporter sets a commit status on every commit in the PR. The head
+// commit carries the result for the PR as a whole.
+type statusReporter struct{}
+
+func (statusReporter) Report(client *github.Client, owner, repo, headSHA string, results []commitResult, cfg *repoConfig) error {
+	unsigned := unsignedSHAs(results)
+
+	var lastErr error
+	for _, result := range results {
+		sha := *result.Commit.SHA
+		status := github.RepoStatus{}
+		status.TargetURL = s(cfg.HelpURL)
+		status.Context = s(cfg.Context)
+		switch {
+		case sha == headSHA && len(unsigned) > 0:
+			status.State = s("failure")
+			status.Description = s(truncate(cfg.Messages.Failure+" "+strings.Join(cfg.failures(results), ", ")+exemptSuffix(results), maxDescriptionLen))
+		case sha == headSHA:
+			status.State = s("success")
+			status.Description = s(truncate(cfg.Messages.Success+exemptSuffix(results), maxDescriptionLen))
+		case result.Exempt != "":
+			status.State = s("success")
+			status.Description = s(truncate("Exempt: "+result.Exempt, maxDescriptionLen))
+		case result.Err != nil:
+			status.State = s("failure")
+			status.Description = s(truncate(fmt.Sprintf("Commit %s: %v", shortSHA(sha), result.Err), maxDescriptionLen))
+		default:
+			status.State = s("success")
+			status.Description = s(truncate("Commit "+cfg.rule.Description(), maxDescriptionLen))
+		}
+
+		current, err := currentStatus(client, owner, repo, sha, cfg.Context)
+		if err != nil {
+			lastErr = fmt.Errorf("getting status of %s: %v", shortSHA(sha), err)
+		} else if current != nil && current.GetState() == *status.State &&
+			current.GetDescription() == *status.Description && current.GetTargetURL() == *status.TargetURL {
+			continue
+		}
+
+		err = callGitHub("Repositories.CreateStatus", fmt.Sprintf("Setting status on %s/%s@%s", owner, repo, shortSHA(sha)), func() (*github.Response, error) {
+			_, resp, err := client.Repositories.CreateStatus(context.TODO(), owner, repo, sha, &status)
+			return resp, err
+		})
+		if err != nil {
+			lastErr = fmt.Errorf("setting status on %s: %v", shortSHA(sha), err)
+		}
+	}
+	return lastErr
+}
+
+func (statusReporter) Override(client *github.Client, owner, repo, headSHA, description string, cfg *repoConfig) error {
+	status := github.RepoStatus{
+		State:       s("success"),
+		Description: s(truncate(description, maxDescriptionLen)),
+		TargetURL:   s(cfg.HelpURL),
+		Context:     s(cfg.Context),
+	}
+	return callGitHub("Repositories.CreateStatus", fmt.Sprintf("Setting status on %s/%s@%s", owner, repo, shortSHA(headSHA)), func() (*github.Response, error) {
+		_, resp, err := client.Repositories.CreateStatus(context.TODO(), owner, repo, headSHA, &status)
+		return resp, err
+	})
+}
+
+func (statusReporter) Overridden(client *github.Client, owner, repo, headSHA string, cfg *repoConfig) (bool, error) {
+	current, err := currentStatus(client, owner, repo, headSHA, cfg.Context)
+	if err != nil || current == nil {
+		return false, err
+	}
+	return current.GetState() == "success" && strings.HasPrefix(current.GetDescription(), overridePrefix), nil
+}
+
+// currentStatus returns the latest status with the given context on a
+// commit, or nil if there isn't one.
+func currentStatus(client *github.Client, owner, repo, sha, statusContext string) (*github.RepoStatus, error) {
+	var combined *github.CombinedStatus
+	err := callGitHub("Repositories.GetCombinedStatus", fmt.Sprintf("Getting status of %s/%s@%s", owner, repo, shortSHA(sha)), func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		combined, resp, err = client.Repositories.GetCombinedStatus(context.TODO(), owner, repo, sha, &github.ListOptions{PerPage: 100})
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range combined.Statuses {
+		if combined.Statuses[i].GetContext() == statusContext {
+			return &combined.Statuses[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// exemptSuffix notes how many of the commits were exempt, if any were.
+func exemptSuffix(results []commitResult) string {
+	exempt := 0
+	for _, result := range results {
+		if result.Exempt != "" {
+			exempt++
+		}
+	}
+	if exempt == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d exempt)", exempt)
+}
+
+// unsignedSHAs returns the short SHAs of the commits that failed the check.
+func unsignedSHAs(results []commitResult) []string {
+	unsigned := []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			unsigned = append(unsigned, shortSHA(*result.Commit.SHA))
+		}
+	}
+	return unsigned
+}
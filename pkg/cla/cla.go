@@ -0,0 +1,181 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cla looks up who has signed a Contributor License Agreement,
+// either individually or through a corporate agreement signed by their
+// employer.
+package cla
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry records the signed CLAs.
+type Registry interface {
+	// Signer returns who signed the CLA covering the GitHub login or email,
+	// or "" if none does. Either may be empty. Anyone can put any email in
+	// a commit, so callers should only pass one that is known to belong to
+	// the author, such as that of a commit GitHub has linked to an account.
+	Signer(login, email string) (string, error)
+}
+
+// Individual is a person who has signed the individual CLA.
+type Individual struct {
+	Name   string   `json:"name"`
+	Login  string   `json:"login"`
+	Emails []string `json:"emails"`
+}
+
+// Corporation is a company that has signed the corporate CLA on behalf of
+// its employees.
+type Corporation struct {
+	Name string `json:"name"`
+
+	// Logins and Emails are the employees who are covered.
+	Logins []string `json:"logins"`
+	Emails []string `json:"emails"`
+
+	// Domains covers everyone with an email address at one of the domains,
+	// such as "example.com". They are only matched if TrustDomains is set,
+	// to confirm that anyone able to verify an address at the domains with
+	// GitHub is an employee.
+	Domains      []string `json:"domains"`
+	TrustDomains bool     `json:"trustDomains"`
+}
+
+// Signatures is the contents of a registry file.
+type Signatures struct {
+	Individuals  []Individual  `json:"individuals"`
+	Corporations []Corporation `json:"corporations"`
+}
+
+// Signer returns who signed the CLA covering the login or email.
+func (sigs *Signatures) Signer(login, email string) (string, error) {
+	domain := ""
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		domain = email[i+1:]
+	}
+	for _, ind := range sigs.Individuals {
+		if matches(login, ind.Login) || matchesAny(email, ind.Emails) {
+			return ind.Name, nil
+		}
+	}
+	for _, corp := range sigs.Corporations {
+		if matchesAny(login, corp.Logins) || matchesAny(email, corp.Emails) || (corp.TrustDomains && matchesAny(domain, corp.Domains)) {
+			return corp.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// validate checks for entries that can never match or can't be reported.
+func (sigs *Signatures) validate() error {
+	for i, ind := range sigs.Individuals {
+		if ind.Name == "" {
+			return fmt.Errorf("individual %d has no name", i+1)
+		}
+		if ind.Login == "" && len(ind.Emails) == 0 {
+			return fmt.Errorf("individual %q has no login or emails", ind.Name)
+		}
+	}
+	for i, corp := range sigs.Corporations {
+		if corp.Name == "" {
+			return fmt.Errorf("corporation %d has no name", i+1)
+		}
+		if len(corp.Logins) == 0 && len(corp.Emails) == 0 && len(corp.Domains) == 0 {
+			return fmt.Errorf("corporation %q has no logins, emails or domains", corp.Name)
+		}
+		if len(corp.Domains) > 0 && !corp.TrustDomains {
+			return fmt.Errorf("corporation %q lists domains without trustDomains", corp.Name)
+		}
+	}
+	return nil
+}
+
+func matches(value, want string) bool {
+	return value != "" && strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(want))
+}
+
+func matchesAny(value string, wants []string) bool {
+	for _, want := range wants {
+		if matches(value, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// FileRegistry reads signatures from a JSON file. The file is read again
+// whenever it changes, so signatures can be added without a restart.
+type FileRegistry struct {
+	file string
+
+	mu      sync.Mutex
+	modTime time.Time
+	sigs    *Signatures
+}
+
+// NewFileRegistry returns a registry backed by file, which must exist and
+// be valid.
+func NewFileRegistry(file string) (*FileRegistry, error) {
+	r := &FileRegistry{file: file}
+	if _, err := r.signatures(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileRegistry) Signer(login, email string) (string, error) {
+	sigs, err := r.signatures()
+	if err != nil {
+		return "", err
+	}
+	return sigs.Signer(login, email)
+}
+
+// signatures returns the contents of the file, reading it if it has changed
+// since it was last read.
+func (r *FileRegistry) signatures() (*Signatures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.file)
+	if err != nil {
+		return nil, err
+	}
+	if r.sigs != nil && info.ModTime().Equal(r.modTime) {
+		return r.sigs, nil
+	}
+	data, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		return nil, err
+	}
+	sigs := &Signatures{}
+	if err := json.Unmarshal(data, sigs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filepath.Base(r.file), err)
+	}
+	if err := sigs.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(r.file), err)
+	}
+	r.sigs, r.modTime = sigs, info.ModTime()
+	return sigs, nil
+}
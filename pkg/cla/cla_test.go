@@ -0,0 +1,77 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cla
+
+import "testing"
+
+func TestSigner(t *testing.T) {
+	sigs := &Signatures{
+		Individuals: []Individual{
+			{Name: "Ann Dev", Login: "ann", Emails: []string{"ann@example.net"}},
+		},
+		Corporations: []Corporation{
+			{Name: "Example Inc.", Logins: []string{"bob"}, Emails: []string{"bob@example.org"}},
+			{Name: "Trusted Corp.", Domains: []string{"trusted.com"}, TrustDomains: true},
+			{Name: "Untrusted Corp.", Domains: []string{"untrusted.com"}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		login, email string
+		want         string
+	}{
+		{name: "individual login", login: "ANN", want: "Ann Dev"},
+		{name: "individual email", email: "Ann@Example.net", want: "Ann Dev"},
+		{name: "corporate login", login: "bob", want: "Example Inc."},
+		{name: "corporate email", email: "bob@example.org", want: "Example Inc."},
+		{name: "trusted domain", login: "carol", email: "carol@trusted.com", want: "Trusted Corp."},
+		{name: "untrusted domain", login: "dave", email: "dave@untrusted.com"},
+		{name: "nobody", login: "eve", email: "eve@example.com"},
+		{name: "empty", login: "", email: ""},
+	}
+	for _, tt := range tests {
+		got, err := sigs.Signer(tt.login, tt.email)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Signer(%q, %q) = %q, want %q", tt.name, tt.login, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		sigs Signatures
+		ok   bool
+	}{
+		{name: "valid", sigs: Signatures{Individuals: []Individual{{Name: "Ann", Login: "ann"}}}, ok: true},
+		{name: "unnamed individual", sigs: Signatures{Individuals: []Individual{{Login: "ann"}}}},
+		{name: "individual without login or emails", sigs: Signatures{Individuals: []Individual{{Name: "Ann"}}}},
+		{name: "corporation without members", sigs: Signatures{Corporations: []Corporation{{Name: "Example Inc."}}}},
+		{name: "domains without trustDomains", sigs: Signatures{Corporations: []Corporation{{Name: "Example Inc.", Domains: []string{"example.com"}}}}},
+		{name: "trusted domains", sigs: Signatures{Corporations: []Corporation{{Name: "Example Inc.", Domains: []string{"example.com"}, TrustDomains: true}}}, ok: true},
+	}
+	for _, tt := range tests {
+		err := tt.sigs.validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ghapp authenticates as a GitHub App and mints installation access
+// tokens for it.
+package ghapp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const mediaTypeIntegrationPreview = "application/vnd.github.machine-man-preview+json"
+
+// refreshBefore is how long before expiry an installation token is replaced.
+const refreshBefore = 5 * time.Minute
+
+// App is a GitHub App identified by its ID and private key. It caches one
+// access token per installation and is safe for concurrent use.
+type App struct {
+	ID int
+
+	// BaseURL is the GitHub API to request tokens from. It must have a
+	// trailing slash.
+	BaseURL *url.URL
+
+	// HTTPClient is used to request tokens. A client with a 30 second
+	// timeout is used if it is nil.
+	HTTPClient *http.Client
+
+	key *rsa.PrivateKey
+
+	// mu guards tokens and botLogin. Each installation has its own lock so
+	// that a slow token request only holds up work for that installation.
+	mu       sync.Mutex
+	tokens   map[int]*installationToken
+	botLogin string
+}
+
+// installationToken is the cached token of one installation.
+type installationToken struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// defaultClient requests tokens if App.HTTPClient isn't set.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// New returns an App for the given ID and PEM encoded private key, as
+// downloaded from the app's settings page.
+func New(id int, keyPEM []byte) (*App, error) {
+	key, err := parseKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	baseURL, _ := url.Parse("https://api.github.com/")
+	return &App{
+		ID:      id,
+		BaseURL: baseURL,
+		key:     key,
+		tokens:  map[int]*installationToken{},
+	}, nil
+}
+
+func parseKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// JWT returns a token authenticating as the app itself. It is valid for a
+// few minutes.
+func (a *App) JWT() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		// Backdated to allow for clock drift between us and GitHub.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": int64(a.ID),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Token returns an access token for the installation, minting a new one if
+// there is no cached token or it is close to expiring.
+func (a *App) Token(installationID int) (*oauth2.Token, error) {
+	a.mu.Lock()
+	it, ok := a.tokens[installationID]
+	if !ok {
+		it = &installationToken{}
+		a.tokens[installationID] = it
+	}
+	a.mu.Unlock()
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.token != nil && time.Now().Add(refreshBefore).Before(it.token.Expiry) {
+		return it.token, nil
+	}
+	t, err := a.createToken(installationID)
+	if err != nil {
+		return nil, err
+	}
+	it.token = t
+	return t, nil
+}
+
+func (a *App) createToken(installationID int) (*oauth2.Token, error) {
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("app/installations/%d/access_tokens", installationID)
+	if err := a.call("POST", path, http.StatusCreated, &result); err != nil {
+		return nil, fmt.Errorf("creating token for installation %d: %v", installationID, err)
+	}
+	return &oauth2.Token{
+		AccessToken: result.Token,
+		TokenType:   "token",
+		Expiry:      result.ExpiresAt,
+	}, nil
+}
+
+// Verify checks that GitHub accepts the app's credentials.
+func (a *App) Verify() error {
+	_, err := a.getApp()
+	return err
+}
+
+// BotLogin returns the login of the app's bot account, which is what
+// comments and other changes made with installation tokens are attributed
+// to. It is looked up once and then cached.
+func (a *App) BotLogin() (string, error) {
+	a.mu.Lock()
+	login := a.botLogin
+	a.mu.Unlock()
+	if login != "" {
+		return login, nil
+	}
+
+	slug, err := a.getApp()
+	if err != nil {
+		return "", err
+	}
+	if slug == "" {
+		return "", fmt.Errorf("app %d has no slug", a.ID)
+	}
+	login = slug + "[bot]"
+	a.mu.Lock()
+	a.botLogin = login
+	a.mu.Unlock()
+	return login, nil
+}
+
+// getApp gets the app itself from GitHub and returns its slug.
+func (a *App) getApp() (string, error) {
+	var result struct {
+		Slug string `json:"slug"`
+	}
+	if err := a.call("GET", "app", http.StatusOK, &result); err != nil {
+		return "", fmt.Errorf("getting app %d: %v", a.ID, err)
+	}
+	return result.Slug, nil
+}
+
+// RepoInstallation returns the ID of the installation of the app on a repo.
+func (a *App) RepoInstallation(owner, repo string) (int, error) {
+	var result struct {
+		ID int `json:"id"`
+	}
+	path := fmt.Sprintf("repos/%s/%s/installation", owner, repo)
+	if err := a.call("GET", path, http.StatusOK, &result); err != nil {
+		return 0, fmt.Errorf("getting installation for %s/%s: %v", owner, repo, err)
+	}
+	return result.ID, nil
+}
+
+// call makes a request to path, relative to BaseURL, authenticated as the
+// app itself. It fails unless the response has the status want, and decodes
+// the response body into result.
+func (a *App) call(method, path string, want int, result interface{}) error {
+	jwt, err := a.JWT()
+	if err != nil {
+		return err
+	}
+	u := a.BaseURL.ResolveReference(&url.URL{Path: path})
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", mediaTypeIntegrationPreview)
+
+	resp, err := a.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (a *App) httpClient() *http.Client {
+	if a.HTTPClient == nil {
+		return defaultClient
+	}
+	return a.HTTPClient
+}
+
+// TokenSource returns a token source for the installation backed by the
+// app's token cache.
+func (a *App) TokenSource(installationID int) oauth2.TokenSource {
+	return installationTokenSource{app: a, id: installationID}
+}
+
+type installationTokenSource struct {
+	app *App
+	id  int
+}
+
+func (s installationTokenSource) Token() (*oauth2.Token, error) {
+	return s.app.Token(s.id)
+}
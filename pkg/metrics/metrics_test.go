@@ -0,0 +1,114 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns what Handler serves.
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	return w.Body.String()
+}
+
+// expectLines checks that lines appear in the output, one after another.
+func expectLines(t *testing.T, out string, lines ...string) {
+	want := strings.Join(lines, "\n") + "\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("output doesn't contain\n%s\ngot\n%s", want, out)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Requests handled.", "code", "method")
+	c.Inc("200", "GET")
+	c.Add(2.5, "200", "GET")
+	c.Inc("500", "POST")
+	c.Inc("404", "GET")
+
+	expectLines(t, scrape(t),
+		"# HELP test_requests_total Requests handled.",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{code="200",method="GET"} 3.5`,
+		`test_requests_total{code="404",method="GET"} 1`,
+		`test_requests_total{code="500",method="POST"} 1`,
+	)
+}
+
+func TestGauges(t *testing.T) {
+	g := NewGaugeVec("test_temperature", "Temperature.\nIn \\degrees.")
+	g.Set(-3)
+	g.Set(21.5)
+	NewGaugeFunc("test_answer", "The answer.", func() float64 { return 42 })
+
+	out := scrape(t)
+	expectLines(t, out,
+		`# HELP test_temperature Temperature.\nIn \\degrees.`,
+		"# TYPE test_temperature gauge",
+		"test_temperature 21.5",
+	)
+	expectLines(t, out,
+		"# HELP test_answer The answer.",
+		"# TYPE test_answer gauge",
+		"test_answer 42",
+	)
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := NewCounterVec("test_escaped_total", "Escaped labels.", "path")
+	c.Inc(`C:\dir "quoted"` + "\nline")
+	expectLines(t, scrape(t), `test_escaped_total{path="C:\\dir \"quoted\"\nline"} 1`)
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1, 10}, "endpoint")
+	for _, v := range []float64{0.05, 0.1, 0.5, 5, 50} {
+		h.Observe(v, "get")
+	}
+
+	expectLines(t, scrape(t),
+		"# HELP test_duration_seconds Durations.",
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{endpoint="get",le="0.1"} 2`,
+		`test_duration_seconds_bucket{endpoint="get",le="1"} 3`,
+		`test_duration_seconds_bucket{endpoint="get",le="10"} 4`,
+		`test_duration_seconds_bucket{endpoint="get",le="+Inf"} 5`,
+		`test_duration_seconds_sum{endpoint="get"} 55.65`,
+		`test_duration_seconds_count{endpoint="get"} 5`,
+	)
+}
+
+func TestWrongNumberOfLabelsPanics(t *testing.T) {
+	c := NewCounterVec("test_labelled_total", "Labelled.", "a", "b")
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Inc with too few label values didn't panic")
+		}
+		if msg, _ := r.(string); !strings.Contains(msg, "test_labelled_total has 2 labels, got 1 values") {
+			t.Errorf("panicked with %v", r)
+		}
+	}()
+	c.Inc("x")
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultNoreplyDomain is the domain of the email addresses github.com uses
+// for people who keep their email private, either "login@" or "id+login@".
+// GitHub Enterprise uses users.noreply.<host> instead.
+const DefaultNoreplyDomain = "users.noreply.github.com"
+
+// remediationRE matches a remediation line, by which someone adds their
+// Signed-off-by to an earlier commit.
+var remediationRE = regexp.MustCompile(`(?mi)^I, ([^<>]*?) <([^<>\s@]+@[^<>\s]+)>, hereby add my Signed-off-by to this commit: ([0-9a-f]{7,40})\s*$`)
+
+// DCO checks commits against the Developer Certificate of Origin
+// (https://developercertificate.org/) the way the DCO GitHub app does. The
+// author of each commit, and every co-author named in a Co-authored-by
+// trailer, must sign off with a matching email, compared without regard to
+// case. Someone whose author email is a GitHub noreply address may instead
+// sign off with their name or another noreply address for the same login.
+//
+// A missing sign-off can be added later by a remediation commit in the same
+// range with a line of the form:
+//
+//	I, Name <email>, hereby add my Signed-off-by to this commit: <sha>
+//
+// The remediation commit must itself pass, and Name <email> must be its
+// author or one of its sign-offs. Merge commits are not checked.
+type DCO struct {
+	name string
+
+	// noreplySuffix is "@" followed by the noreply domain.
+	noreplySuffix string
+}
+
+// NewDCO returns a DCO rule. noreplyDomain is the domain of GitHub's noreply
+// email addresses, and defaults to DefaultNoreplyDomain.
+func NewDCO(name, noreplyDomain string) (*DCO, error) {
+	if noreplyDomain == "" {
+		noreplyDomain = DefaultNoreplyDomain
+	}
+	return &DCO{name: name, noreplySuffix: "@" + strings.ToLower(noreplyDomain)}, nil
+}
+
+func (d *DCO) Name() string { return d.name }
+
+func (d *DCO) Description() string {
+	return "is signed off by its authors"
+}
+
+// RequiresSignOff is always true, since a DCO needs Signed-off-by trailers.
+func (d *DCO) RequiresSignOff() bool { return true }
+
+func (d *DCO) Check(commit *Commit) error {
+	return d.check(commit, nil)
+}
+
+// CheckRange checks commits, counting the remediations made by any of them.
+func (d *DCO) CheckRange(commits []*Commit) []error {
+	remediations := map[string][]Identity{}
+	for _, commit := range commits {
+		if d.check(commit, nil) != nil {
+			continue
+		}
+		for _, m := range remediationRE.FindAllStringSubmatch(commit.Message, -1) {
+			id := Identity{Name: strings.TrimSpace(m[1]), Email: m[2]}
+			if !d.signedBy(commit, id) {
+				continue
+			}
+			for _, target := range commits {
+				if strings.HasPrefix(strings.ToLower(target.SHA), strings.ToLower(m[3])) {
+					remediations[target.SHA] = append(remediations[target.SHA], id)
+				}
+			}
+		}
+	}
+
+	errs := make([]error, len(commits))
+	for i, commit := range commits {
+		errs[i] = d.check(commit, remediations[commit.SHA])
+	}
+	return errs
+}
+
+// check checks a single commit, counting extra as sign-offs.
+func (d *DCO) check(commit *Commit, extra []Identity) error {
+	if commit.Merge {
+		return nil
+	}
+	signOffs, err := parseSignOffs(commit.Message)
+	if err != nil {
+		return err
+	}
+	signOffs = append(signOffs, extra...)
+	if len(signOffs) == 0 {
+		return errors.New("missing Signed-off-by")
+	}
+	if !d.signedOff(signOffs, commit.Author) {
+		return fmt.Errorf("no Signed-off-by matches the author %s <%s>", commit.Author.Name, commit.Author.Email)
+	}
+	for _, value := range TrailerValues(commit.Message, CoAuthoredBy) {
+		coAuthor, err := ParseIdentity(value)
+		if err != nil {
+			return fmt.Errorf("malformed %s: %q", CoAuthoredBy, value)
+		}
+		if !d.signedOff(signOffs, coAuthor) {
+			return fmt.Errorf("co-author %s <%s> has not signed off", coAuthor.Name, coAuthor.Email)
+		}
+	}
+	return nil
+}
+
+// signedBy reports whether id is the author of commit or has signed it
+// off.
+func (d *DCO) signedBy(commit *Commit, id Identity) bool {
+	if d.matches(id, commit.Author) {
+		return true
+	}
+	signOffs, err := parseSignOffs(commit.Message)
+	return err == nil && d.signedOff(signOffs, id)
+}
+
+// signedOff reports whether any of signOffs is by who.
+func (d *DCO) signedOff(signOffs []Identity, who Identity) bool {
+	for _, so := range signOffs {
+		if d.matches(so, who) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether a sign-off is by who. Emails are compared
+// without regard to case. Noreply addresses match if they are for the same
+// login, and a sign-off by name is accepted for someone whose email is a
+// noreply address, since GitHub puts that on commits made in its web UI.
+func (d *DCO) matches(so, who Identity) bool {
+	if strings.EqualFold(strings.TrimSpace(so.Email), strings.TrimSpace(who.Email)) {
+		return true
+	}
+	login, ok := d.noreplyLogin(who.Email)
+	if !ok {
+		return false
+	}
+	if soLogin, ok := d.noreplyLogin(so.Email); ok && strings.EqualFold(soLogin, login) {
+		return true
+	}
+	return who.Name != "" && strings.EqualFold(strings.TrimSpace(so.Name), strings.TrimSpace(who.Name))
+}
+
+// noreplyLogin returns the login in a GitHub noreply email address.
+func (d *DCO) noreplyLogin(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	if !strings.HasSuffix(strings.ToLower(email), d.noreplySuffix) {
+		return "", false
+	}
+	local := email[:len(email)-len(d.noreplySuffix)]
+	if i := strings.Index(local, "+"); i >= 0 {
+		local = local[i+1:]
+	}
+	return local, local != ""
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import "testing"
+
+func TestDCOCheck(t *testing.T) {
+	dco, _ := NewDCO("dco", "")
+	noreply := Identity{Name: "Jane Dev", Email: "12345+jdev@users.noreply.github.com"}
+
+	tests := []struct {
+		name    string
+		commit  Commit
+		wantErr string
+	}{
+		{
+			name:   "author signed off",
+			commit: Commit{Message: "Fix\n\nSigned-off-by: Jane Dev <JANE@example.com>", Author: jane},
+		},
+		{
+			name:    "only the committer signed off",
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: Bob Maintainer <bob@example.com>", Author: jane, Committer: bob},
+			wantErr: "no Signed-off-by matches the author Jane Dev <jane@example.com>",
+		},
+		{
+			name:    "name alone doesn't match",
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: Jane Dev <jane@elsewhere.com>", Author: jane},
+			wantErr: "no Signed-off-by matches the author Jane Dev <jane@example.com>",
+		},
+		{
+			name:   "noreply author signed off with the plain noreply address",
+			commit: Commit{Message: "Fix\n\nSigned-off-by: J <jdev@users.noreply.github.com>", Author: noreply},
+		},
+		{
+			name:   "noreply author signed off by name",
+			commit: Commit{Message: "Fix\n\nSigned-off-by: jane dev <jane@example.com>", Author: noreply},
+		},
+		{
+			name:    "noreply address of another login",
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: J <999+other@users.noreply.github.com>", Author: noreply},
+			wantErr: "no Signed-off-by matches the author Jane Dev <12345+jdev@users.noreply.github.com>",
+		},
+		{
+			name:   "co-author signed off",
+			commit: Commit{Message: "Fix\n\nCo-authored-by: Bob Maintainer <bob@example.com>\nSigned-off-by: Jane Dev <jane@example.com>\nSigned-off-by: Bob <Bob@example.com>", Author: jane},
+		},
+		{
+			name:    "co-author didn't sign off",
+			commit:  Commit{Message: "Fix\n\nCo-authored-by: Bob Maintainer <bob@example.com>\nSigned-off-by: Jane Dev <jane@example.com>", Author: jane},
+			wantErr: "co-author Bob Maintainer <bob@example.com> has not signed off",
+		},
+		{
+			name:    "malformed co-author",
+			commit:  Commit{Message: "Fix\n\nCo-authored-by: bob\nSigned-off-by: Jane Dev <jane@example.com>", Author: jane},
+			wantErr: `malformed Co-authored-by: "bob"`,
+		},
+		{
+			name:    "missing",
+			commit:  Commit{Message: "Fix", Author: jane},
+			wantErr: "missing Signed-off-by",
+		},
+		{
+			name:   "merge",
+			commit: Commit{Message: "Merge branch 'main'", Author: jane, Merge: true},
+		},
+	}
+	for _, tt := range tests {
+		err := dco.Check(&tt.commit)
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestDCONoreplyDomain(t *testing.T) {
+	dco, _ := NewDCO("dco", "users.noreply.ghe.example.com")
+	author := Identity{Name: "Jane Dev", Email: "12345+jdev@users.noreply.ghe.example.com"}
+
+	tests := []struct {
+		name    string
+		signOff string
+		wantErr string
+	}{
+		{name: "by name", signOff: "Jane Dev <jane@example.com>"},
+		{name: "plain noreply address", signOff: "J <jdev@users.noreply.ghe.example.com>"},
+		{
+			name:    "github.com noreply address",
+			signOff: "J <jdev@users.noreply.github.com>",
+			wantErr: "no Signed-off-by matches the author Jane Dev <12345+jdev@users.noreply.ghe.example.com>",
+		},
+	}
+	for _, tt := range tests {
+		err := dco.Check(&Commit{Message: "Fix\n\nSigned-off-by: " + tt.signOff, Author: author})
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestDCORemediation(t *testing.T) {
+	dco, _ := NewDCO("dco", "")
+	const unsigned = "0123456789abcdef0123456789abcdef01234567"
+	remediation := func(who, signOff string, author Identity) *Commit {
+		return &Commit{
+			SHA:     "fedcba9876543210fedcba9876543210fedcba98",
+			Message: "DCO remediation\n\nI, " + who + ", hereby add my Signed-off-by to this commit: 0123456789\n\nSigned-off-by: " + signOff,
+			Author:  author,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		remediation *Commit
+		wantErr     string
+	}{
+		{
+			name:        "by the author",
+			remediation: remediation("Jane Dev <jane@example.com>", "Jane Dev <jane@example.com>", jane),
+		},
+		{
+			name:        "recorded by a maintainer with the author's sign-off",
+			remediation: remediation("Jane Dev <jane@example.com>", "Jane Dev <jane@example.com>\nSigned-off-by: Bob Maintainer <bob@example.com>", bob),
+		},
+		{
+			name:        "by someone who isn't the author",
+			remediation: remediation("Bob Maintainer <bob@example.com>", "Bob Maintainer <bob@example.com>", bob),
+			wantErr:     "no Signed-off-by matches the author Jane Dev <jane@example.com>",
+		},
+		{
+			name:        "naming the author without their sign-off",
+			remediation: remediation("Jane Dev <jane@example.com>", "Bob Maintainer <bob@example.com>", bob),
+			wantErr:     "missing Signed-off-by",
+		},
+		{
+			name: "in a commit that fails itself",
+			remediation: &Commit{
+				SHA:     "fedcba9876543210fedcba9876543210fedcba98",
+				Message: "DCO remediation\n\nI, Jane Dev <jane@example.com>, hereby add my Signed-off-by to this commit: 0123456789",
+				Author:  jane,
+			},
+			wantErr: "missing Signed-off-by",
+		},
+	}
+	for _, tt := range tests {
+		commits := []*Commit{{SHA: unsigned, Message: "Fix", Author: jane}, tt.remediation}
+		errs := dco.CheckRange(commits)
+		if got := errString(errs[0]); got != tt.wantErr {
+			t.Errorf("%s: got error %q for the remediated commit, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy checks commits against rules about their messages, such as
+// requiring a Signed-off-by trailer or limiting the length of the subject.
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Commit is what rules know about a commit.
+type Commit struct {
+	SHA       string
+	Message   string
+	Author    Identity
+	Committer Identity
+
+	// AuthorLogin and CommitterLogin are the GitHub logins of the author
+	// and committer, if they are known.
+	AuthorLogin    string
+	CommitterLogin string
+
+	// Verification is GitHub's verification of the commit's signature, or
+	// nil if it isn't known.
+	Verification *Verification
+
+	// Merge is set for commits with more than one parent. Their subjects
+	// are usually written by git, so rules about subjects skip them.
+	Merge bool
+}
+
+// Identity is the author or committer of a commit.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Verification is the outcome of verifying a commit's signature.
+type Verification struct {
+	Verified bool
+
+	// Reason is GitHub's reason for the outcome, such as "valid",
+	// "unsigned", "unknown_key" or "bad_email".
+	Reason string
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// Rule is a check applied to every commit.
+type Rule interface {
+	// Name identifies the rule. Each rule is reported as its own status
+	// under this name.
+	Name() string
+
+	// Description says what a commit that passes the rule is like, in a
+	// form that follows "Commit", such as "has Signed-off-by".
+	Description() string
+
+	// Check returns nil if the commit passes the rule, and an error
+	// describing the first problem otherwise.
+	Check(commit *Commit) error
+}
+
+// RangeRule is implemented by rules that need to see every commit being
+// checked at once, such as one commit that fixes another.
+type RangeRule interface {
+	Rule
+
+	// CheckRange returns the outcome of checking each of the commits.
+	CheckRange(commits []*Commit) []error
+}
+
+// CheckAll applies a rule to commits, in one go if it is a RangeRule and one
+// at a time otherwise.
+func CheckAll(rule Rule, commits []*Commit) []error {
+	if r, ok := rule.(RangeRule); ok {
+		return r.CheckRange(commits)
+	}
+	errs := make([]error, len(commits))
+	for i, commit := range commits {
+		errs[i] = rule.Check(commit)
+	}
+	return errs
+}
+
+// Types of rule that can be configured.
+const (
+	TypeTrailers            = "trailers"
+	TypeDCO                 = "dco"
+	TypeForbiddenPatterns   = "forbidden-patterns"
+	TypeSubjectLength       = "subject-length"
+	TypeConventionalCommits = "conventional-commits"
+	TypeSignature           = "signature"
+)
+
+// Config selects and configures a rule. Only the fields for its type are
+// used.
+type Config struct {
+	// Type is one of the Type constants.
+	Type string `json:"type"`
+
+	// Name is the name of the rule. It defaults to the type.
+	Name string `json:"name"`
+
+	// Trailers are the trailers a "trailers" rule requires.
+	Trailers []string `json:"trailers"`
+
+	// Optional are the trailers a "trailers" rule checks are well formed
+	// if they are present, such as Co-authored-by.
+	Optional []string `json:"optional"`
+
+	// Patterns are regular expressions that a "forbidden-patterns" rule
+	// rejects subjects matching.
+	Patterns []string `json:"patterns"`
+
+	// Max is the longest subject a "subject-length" rule allows.
+	Max int `json:"max"`
+
+	// Types are the types a "conventional-commits" rule allows. They
+	// default to DefaultConventionalTypes.
+	Types []string `json:"types"`
+
+	// AuthorKey makes a "signature" rule require the signing key to belong
+	// to the author.
+	AuthorKey bool `json:"authorKey"`
+
+	// NoreplyDomain is the domain of GitHub's noreply email addresses for
+	// a "dco" rule. It comes from the checker's settings rather than repo
+	// config, and defaults to DefaultNoreplyDomain.
+	NoreplyDomain string `json:"-"`
+}
+
+// New returns the rule described by a config.
+func New(cfg Config) (Rule, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+	switch cfg.Type {
+	case TypeTrailers:
+		return NewTrailers(name, cfg.Trailers, cfg.Optional)
+	case TypeDCO:
+		return NewDCO(name, cfg.NoreplyDomain)
+	case TypeForbiddenPatterns:
+		return NewForbiddenPatterns(name, cfg.Patterns)
+	case TypeSubjectLength:
+		return NewSubjectLength(name, cfg.Max)
+	case TypeConventionalCommits:
+		return NewConventionalCommits(name, cfg.Types)
+	case TypeSignature:
+		return NewSignature(name, cfg.AuthorKey)
+	}
+	return nil, fmt.Errorf("unknown rule type %q", cfg.Type)
+}
+
+// NewSet returns the rules described by configs. Rule names must be unique.
+func NewSet(configs []Config) ([]Rule, error) {
+	rules := []Rule{}
+	names := map[string]bool{}
+	for i, cfg := range configs {
+		rule, err := New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		if names[rule.Name()] {
+			return nil, fmt.Errorf("rule %d: there is already a rule named %q", i+1, rule.Name())
+		}
+		names[rule.Name()] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
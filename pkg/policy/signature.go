@@ -0,0 +1,85 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signature requires commits to have a GPG or SSH signature that GitHub has
+// verified. GitHub verifies a signature against the keys of the account
+// whose verified email is the committer email, so with AuthorKey set the
+// committer must also be the author.
+type Signature struct {
+	name string
+
+	// AuthorKey requires the signing key to belong to the author.
+	AuthorKey bool
+}
+
+// NewSignature returns a rule requiring verified signatures.
+func NewSignature(name string, authorKey bool) (*Signature, error) {
+	return &Signature{name: name, AuthorKey: authorKey}, nil
+}
+
+func (s *Signature) Name() string { return s.name }
+
+func (s *Signature) Description() string {
+	if s.AuthorKey {
+		return "is signed by its author with a verified key"
+	}
+	return "has a verified signature"
+}
+
+// Check fails commits whose signature GitHub couldn't verify, giving
+// GitHub's reason, such as "unsigned", "unknown_key" or "bad_email".
+// Commits without a known verification, such as signed commits checked
+// outside GitHub, pass.
+func (s *Signature) Check(commit *Commit) error {
+	v := commit.Verification
+	if v == nil {
+		return nil
+	}
+	if !v.Verified {
+		reason := v.Reason
+		if reason == "" {
+			reason = "unknown"
+		}
+		return fmt.Errorf("signature is not verified: %s", reason)
+	}
+	if s.AuthorKey && !sameAccount(commit) {
+		return fmt.Errorf("signed by the committer %s rather than the author %s", commitIdentity(commit.CommitterLogin, commit.Committer), commitIdentity(commit.AuthorLogin, commit.Author))
+	}
+	return nil
+}
+
+// sameAccount reports whether the author and committer of a commit are the
+// same person, by GitHub login if both are known and by email otherwise.
+func sameAccount(commit *Commit) bool {
+	if commit.AuthorLogin != "" && commit.CommitterLogin != "" {
+		return strings.EqualFold(commit.AuthorLogin, commit.CommitterLogin)
+	}
+	return commit.Author.Email != "" && strings.EqualFold(strings.TrimSpace(commit.Author.Email), strings.TrimSpace(commit.Committer.Email))
+}
+
+func commitIdentity(login string, id Identity) string {
+	if login != "" {
+		return "@" + login
+	}
+	return fmt.Sprintf("%s <%s>", id.Name, id.Email)
+}
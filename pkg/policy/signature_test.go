@@ -0,0 +1,100 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import "testing"
+
+func TestSignatureCheck(t *testing.T) {
+	verified := &Verification{Verified: true, Reason: "valid"}
+
+	tests := []struct {
+		name      string
+		authorKey bool
+		commit    Commit
+		wantErr   string
+	}{
+		{
+			name:   "verification not known",
+			commit: Commit{Author: jane, Committer: bob},
+		},
+		{
+			name:   "verified",
+			commit: Commit{Author: jane, Committer: bob, Verification: verified},
+		},
+		{
+			name:    "unsigned",
+			commit:  Commit{Verification: &Verification{Reason: "unsigned"}},
+			wantErr: "signature is not verified: unsigned",
+		},
+		{
+			name:    "unknown key",
+			commit:  Commit{Verification: &Verification{Reason: "unknown_key"}},
+			wantErr: "signature is not verified: unknown_key",
+		},
+		{
+			name:    "bad email",
+			commit:  Commit{Verification: &Verification{Reason: "bad_email"}},
+			wantErr: "signature is not verified: bad_email",
+		},
+		{
+			name:    "no reason",
+			commit:  Commit{Verification: &Verification{}},
+			wantErr: "signature is not verified: unknown",
+		},
+		{
+			name:      "author key with matching logins",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: Identity{Name: "Jane", Email: "jdev@example.com"}, AuthorLogin: "jdev", CommitterLogin: "JDev", Verification: verified},
+		},
+		{
+			name:      "author key with other committer login",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: jane, AuthorLogin: "jdev", CommitterLogin: "bob", Verification: verified},
+			wantErr:   "signed by the committer @bob rather than the author @jdev",
+		},
+		{
+			name:      "author key with matching emails and no logins",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: Identity{Name: "J", Email: "JANE@example.com"}, Verification: verified},
+		},
+		{
+			name:      "author key with other committer email and no logins",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: bob, Verification: verified},
+			wantErr:   "signed by the committer Bob Maintainer <bob@example.com> rather than the author Jane Dev <jane@example.com>",
+		},
+		{
+			name:      "author key with one login missing",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: bob, AuthorLogin: "jdev", Verification: verified},
+			wantErr:   "signed by the committer Bob Maintainer <bob@example.com> rather than the author @jdev",
+		},
+		{
+			name:      "author key with unverified signature",
+			authorKey: true,
+			commit:    Commit{Author: jane, Committer: jane, Verification: &Verification{Reason: "unsigned"}},
+			wantErr:   "signature is not verified: unsigned",
+		},
+	}
+	for _, tt := range tests {
+		sig, _ := NewSignature("signature", tt.authorKey)
+		err := sig.Check(&tt.commit)
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ForbiddenPatterns rejects commits whose subject matches any of a set of
+// regular expressions, such as "^fixup!" or `\bWIP\b`.
+type ForbiddenPatterns struct {
+	name     string
+	patterns []*regexp.Regexp
+}
+
+// NewForbiddenPatterns returns a rule rejecting subjects that match any of
+// the patterns.
+func NewForbiddenPatterns(name string, patterns []string) (*ForbiddenPatterns, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no patterns are forbidden")
+	}
+	f := &ForbiddenPatterns{name: name}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+func (f *ForbiddenPatterns) Name() string { return f.name }
+
+func (f *ForbiddenPatterns) Description() string {
+	patterns := []string{}
+	for _, re := range f.patterns {
+		patterns = append(patterns, re.String())
+	}
+	return "subject matches none of " + strings.Join(patterns, ", ")
+}
+
+func (f *ForbiddenPatterns) Check(commit *Commit) error {
+	if commit.Merge {
+		return nil
+	}
+	subject := commit.Subject()
+	for _, re := range f.patterns {
+		if re.MatchString(subject) {
+			return fmt.Errorf("subject matches forbidden pattern %s", re)
+		}
+	}
+	return nil
+}
+
+// SubjectLength limits the length of commit subjects, in characters.
+type SubjectLength struct {
+	name string
+	Max  int
+}
+
+// NewSubjectLength returns a rule limiting subjects to max characters.
+func NewSubjectLength(name string, max int) (*SubjectLength, error) {
+	if max < 1 {
+		return nil, errors.New("max must be at least 1")
+	}
+	return &SubjectLength{name: name, Max: max}, nil
+}
+
+func (l *SubjectLength) Name() string { return l.name }
+
+func (l *SubjectLength) Description() string {
+	return fmt.Sprintf("subject is at most %d characters", l.Max)
+}
+
+func (l *SubjectLength) Check(commit *Commit) error {
+	if commit.Merge {
+		return nil
+	}
+	if n := utf8.RuneCountInString(commit.Subject()); n > l.Max {
+		return fmt.Errorf("subject is %d characters, more than %d", n, l.Max)
+	}
+	return nil
+}
+
+// DefaultConventionalTypes are the commit types allowed by
+// ConventionalCommits if none are configured.
+var DefaultConventionalTypes = []string{
+	"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test",
+}
+
+// conventionalRE matches subjects of the form "type(scope)!: description",
+// where the scope and "!" are optional.
+var conventionalRE = regexp.MustCompile(`^([a-zA-Z]+)(\([^()]+\))?!?: \S`)
+
+// ConventionalCommits requires subjects to follow Conventional Commits
+// (https://www.conventionalcommits.org/) with one of a set of types.
+type ConventionalCommits struct {
+	name  string
+	Types []string
+}
+
+// NewConventionalCommits returns a rule allowing the given types, or
+// DefaultConventionalTypes if there are none.
+func NewConventionalCommits(name string, types []string) (*ConventionalCommits, error) {
+	if len(types) == 0 {
+		types = DefaultConventionalTypes
+	}
+	return &ConventionalCommits{name: name, Types: types}, nil
+}
+
+func (c *ConventionalCommits) Name() string { return c.name }
+
+func (c *ConventionalCommits) Description() string {
+	return "subject follows Conventional Commits"
+}
+
+func (c *ConventionalCommits) Check(commit *Commit) error {
+	if commit.Merge {
+		return nil
+	}
+	m := conventionalRE.FindStringSubmatch(commit.Subject())
+	if m == nil {
+		return errors.New(`subject is not of the form "type(scope): description"`)
+	}
+	for _, t := range c.Types {
+		if strings.EqualFold(m[1], t) {
+			return nil
+		}
+	}
+	return fmt.Errorf("type %q is not one of %s", m[1], strings.Join(c.Types, ", "))
+}
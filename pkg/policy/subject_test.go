@@ -0,0 +1,65 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubjectRules(t *testing.T) {
+	forbidden, err := NewForbiddenPatterns("no-wip", []string{"^fixup!", `\bWIP\b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, _ := NewSubjectLength("subject-length", 10)
+	conventional, _ := NewConventionalCommits("conventional", nil)
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		commit  Commit
+		wantErr string
+	}{
+		{name: "allowed subject", rule: forbidden, commit: Commit{Message: "Fix the WIPER\n\nWIP"}},
+		{name: "forbidden subject", rule: forbidden, commit: Commit{Message: "fixup! Fix"}, wantErr: "subject matches forbidden pattern ^fixup!"},
+		{name: "forbidden merge", rule: forbidden, commit: Commit{Message: "WIP merge", Merge: true}},
+		{name: "short subject", rule: length, commit: Commit{Message: "Fix ünïcø\n\nA much longer body"}},
+		{name: "long subject", rule: length, commit: Commit{Message: "Fix the thing"}, wantErr: "subject is 13 characters, more than 10"},
+		{name: "long merge", rule: length, commit: Commit{Message: "Merge branch 'main'", Merge: true}},
+		{name: "conventional", rule: conventional, commit: Commit{Message: "feat(api)!: drop v1"}},
+		{name: "unknown type", rule: conventional, commit: Commit{Message: "wip: stuff"}, wantErr: `type "wip" is not one of ` + strings.Join(DefaultConventionalTypes, ", ")},
+		{name: "not conventional", rule: conventional, commit: Commit{Message: "Fix stuff"}, wantErr: `subject is not of the form "type(scope): description"`},
+		{name: "conventional merge", rule: conventional, commit: Commit{Message: "Merge pull request #1", Merge: true}},
+	}
+	for _, tt := range tests {
+		err := tt.rule.Check(&tt.commit)
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestNewSetRejectsDuplicateNames(t *testing.T) {
+	_, err := NewSet([]Config{
+		{Type: TypeSubjectLength, Name: "subject", Max: 50},
+		{Type: TypeConventionalCommits, Name: "subject"},
+	})
+	if err == nil {
+		t.Error("NewSet succeeded with two rules named subject, want an error")
+	}
+}
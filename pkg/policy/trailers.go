@@ -0,0 +1,190 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Trailers with a meaning we know, which are checked for being well formed
+// whenever they appear.
+const (
+	SignedOffBy  = "Signed-off-by"
+	CoAuthoredBy = "Co-authored-by"
+	ChangeID     = "Change-Id"
+)
+
+var identityRE = regexp.MustCompile(`^\s*([^<>]*?)\s*<([^<>\s@]+@[^<>\s]+)>\s*$`)
+
+// changeIDRE matches the Change-Id trailers added by Gerrit's commit-msg
+// hook.
+var changeIDRE = regexp.MustCompile(`^I[0-9a-f]{40}$`)
+
+// Trailers requires every commit to have a set of trailers, and checks that
+// optional trailers are well formed when they are present. A Signed-off-by
+// trailer must name the author or committer of the commit, a Co-authored-by
+// trailer must be of the form "Name <email>" and a Change-Id must be one
+// made by Gerrit. Other trailers must not be empty.
+type Trailers struct {
+	name string
+
+	// Required are the keys of the required trailers.
+	Required []string
+
+	// Optional are the keys of trailers that are checked if present.
+	Optional []string
+}
+
+// NewTrailers returns a rule requiring the given trailers.
+func NewTrailers(name string, required, optional []string) (*Trailers, error) {
+	if len(required) == 0 && len(optional) == 0 {
+		return nil, errors.New("no trailers are required")
+	}
+	for _, key := range append(append([]string(nil), required...), optional...) {
+		if key == "" || strings.ContainsAny(key, ": \t\n") {
+			return nil, fmt.Errorf("%q is not a trailer key", key)
+		}
+	}
+	return &Trailers{name: name, Required: required, Optional: optional}, nil
+}
+
+func (t *Trailers) Name() string { return t.name }
+
+func (t *Trailers) Description() string {
+	if len(t.Required) == 0 {
+		return "has well formed " + strings.Join(t.Optional, ", ")
+	}
+	return "has " + strings.Join(t.Required, ", ")
+}
+
+// RequiresSignOff reports whether Signed-off-by is one of the required
+// trailers.
+func (t *Trailers) RequiresSignOff() bool {
+	for _, key := range t.Required {
+		if strings.EqualFold(key, SignedOffBy) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check checks that commit has the required trailers and that every trailer
+// is well formed.
+func (t *Trailers) Check(commit *Commit) error {
+	for _, key := range t.Required {
+		if strings.EqualFold(key, SignedOffBy) {
+			if err := checkSignOff(commit); err != nil {
+				return err
+			}
+			continue
+		}
+		values := TrailerValues(commit.Message, key)
+		if len(values) == 0 {
+			return fmt.Errorf("missing %s", key)
+		}
+		if err := checkTrailers(key, values); err != nil {
+			return err
+		}
+	}
+	for _, key := range t.Optional {
+		if err := checkTrailers(key, TrailerValues(commit.Message, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTrailers checks that every value of a trailer is well formed.
+func checkTrailers(key string, values []string) error {
+	for _, value := range values {
+		ok := value != ""
+		switch {
+		case strings.EqualFold(key, SignedOffBy), strings.EqualFold(key, CoAuthoredBy):
+			_, err := ParseIdentity(value)
+			ok = err == nil
+		case strings.EqualFold(key, ChangeID):
+			ok = changeIDRE.MatchString(value)
+		}
+		if !ok {
+			return fmt.Errorf("malformed %s: %q", key, value)
+		}
+	}
+	return nil
+}
+
+// TrailerValues returns the value of every line in message of the form
+// "key: value". The key is matched without regard to case.
+func TrailerValues(message, key string) []string {
+	re := regexp.MustCompile(`(?mi)^` + regexp.QuoteMeta(key) + `:(.*)$`)
+	values := []string{}
+	for _, m := range re.FindAllStringSubmatch(message, -1) {
+		values = append(values, strings.TrimSpace(m[1]))
+	}
+	return values
+}
+
+// ParseIdentity parses a trailer value of the form "Name <email>".
+func ParseIdentity(value string) (Identity, error) {
+	parts := identityRE.FindStringSubmatch(value)
+	if parts == nil || parts[1] == "" {
+		return Identity{}, fmt.Errorf("%q is not of the form Name <email>", value)
+	}
+	return Identity{Name: parts[1], Email: parts[2]}, nil
+}
+
+// parseSignOffs returns every Signed-off-by trailer in message. An error is
+// returned if any of the trailers is not of the form "Name <email>".
+func parseSignOffs(message string) ([]Identity, error) {
+	signOffs := []Identity{}
+	for _, value := range TrailerValues(message, SignedOffBy) {
+		id, err := ParseIdentity(value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s: %q", SignedOffBy, value)
+		}
+		signOffs = append(signOffs, id)
+	}
+	return signOffs, nil
+}
+
+// matches reports whether a sign-off names the given commit identity. The
+// emails are compared without regard to case; names aren't compared, since
+// anyone can sign off with someone else's name.
+func (id Identity) matches(who Identity) bool {
+	return who.Email != "" && strings.EqualFold(id.Email, strings.TrimSpace(who.Email))
+}
+
+// checkSignOff returns nil if the commit carries a well formed Signed-off-by
+// trailer for its author or committer, and an error describing the problem
+// otherwise.
+func checkSignOff(commit *Commit) error {
+	signOffs, err := parseSignOffs(commit.Message)
+	if err != nil {
+		return err
+	}
+	if len(signOffs) == 0 {
+		return errors.New("missing Signed-off-by")
+	}
+	for _, so := range signOffs {
+		if so.matches(commit.Author) || so.matches(commit.Committer) {
+			return nil
+		}
+	}
+	return errors.New("no Signed-off-by matches the commit author or committer")
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2017 by the contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import "testing"
+
+var (
+	jane = Identity{Name: "Jane Dev", Email: "jane@example.com"}
+	bob  = Identity{Name: "Bob Maintainer", Email: "bob@example.com"}
+)
+
+func TestTrailers(t *testing.T) {
+	signOff, err := NewTrailers("signed-off-by", []string{SignedOffBy}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeID, err := NewTrailers("change-id", []string{ChangeID}, []string{CoAuthoredBy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		rule    *Trailers
+		commit  Commit
+		wantErr string
+	}{
+		{
+			name:   "author signed off",
+			rule:   signOff,
+			commit: Commit{Message: "Fix\n\nSigned-off-by: Jane Dev <jane@example.com>", Author: jane, Committer: jane},
+		},
+		{
+			name:   "email compared without regard to case",
+			rule:   signOff,
+			commit: Commit{Message: "Fix\n\nsigned-off-by: Jane <Jane@Example.COM>", Author: jane, Committer: jane},
+		},
+		{
+			name:   "committer signed off",
+			rule:   signOff,
+			commit: Commit{Message: "Fix\n\nSigned-off-by: Bob Maintainer <bob@example.com>", Author: jane, Committer: bob},
+		},
+		{
+			name:    "neither author nor committer signed off",
+			rule:    signOff,
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: Someone <someone@example.com>", Author: jane, Committer: bob},
+			wantErr: "no Signed-off-by matches the commit author or committer",
+		},
+		{
+			name:    "name alone doesn't match",
+			rule:    signOff,
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: Jane Dev <anything@anywhere.com>", Author: jane, Committer: jane},
+			wantErr: "no Signed-off-by matches the commit author or committer",
+		},
+		{
+			name:    "missing",
+			rule:    signOff,
+			commit:  Commit{Message: "Fix", Author: jane, Committer: jane},
+			wantErr: "missing Signed-off-by",
+		},
+		{
+			name:    "empty",
+			rule:    signOff,
+			commit:  Commit{Message: "Fix\n\nSigned-off-by:", Author: jane, Committer: jane},
+			wantErr: `malformed Signed-off-by: ""`,
+		},
+		{
+			name:    "malformed alongside a valid one",
+			rule:    signOff,
+			commit:  Commit{Message: "Fix\n\nSigned-off-by: Jane Dev <jane@example.com>\nSigned-off-by: jane", Author: jane, Committer: jane},
+			wantErr: `malformed Signed-off-by: "jane"`,
+		},
+		{
+			name:    "merge",
+			rule:    signOff,
+			commit:  Commit{Message: "Merge branch 'main' into fix", Author: jane, Committer: jane, Merge: true},
+			wantErr: "missing Signed-off-by",
+		},
+		{
+			name:   "Change-Id",
+			rule:   changeID,
+			commit: Commit{Message: "Fix\n\nChange-Id: I0123456789abcdef0123456789abcdef01234567"},
+		},
+		{
+			name:    "malformed Change-Id",
+			rule:    changeID,
+			commit:  Commit{Message: "Fix\n\nChange-Id: 1234"},
+			wantErr: `malformed Change-Id: "1234"`,
+		},
+		{
+			name:    "empty Change-Id",
+			rule:    changeID,
+			commit:  Commit{Message: "Fix\n\nChange-Id:"},
+			wantErr: `malformed Change-Id: ""`,
+		},
+		{
+			name:    "malformed optional trailer",
+			rule:    changeID,
+			commit:  Commit{Message: "Fix\n\nCo-authored-by: bob\nChange-Id: I0123456789abcdef0123456789abcdef01234567"},
+			wantErr: `malformed Co-authored-by: "bob"`,
+		},
+	}
+	for _, tt := range tests {
+		err := tt.rule.Check(&tt.commit)
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestNewTrailersRejectsBadKeys(t *testing.T) {
+	for _, keys := range [][]string{nil, {""}, {"Signed-off-by:"}, {"Signed off by"}} {
+		if _, err := NewTrailers("trailers", keys, nil); err == nil {
+			t.Errorf("NewTrailers(%q) succeeded, want an error", keys)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}